@@ -1,6 +1,7 @@
 package main
 
 import (
+	"GPTGRAMM/internal/api"
 	"GPTGRAMM/internal/bot"
 	"GPTGRAMM/internal/config"
 	"context"
@@ -16,7 +17,27 @@ func main() {
 		log.Fatal("Не вказано токен Telegram бота")
 	}
 
-	myBot, err := bot.NewBot(cfg.TelegramToken)
+	if cfg.TokenizerFile != "" {
+		tokenizer, err := api.LoadTokenizer(cfg.TokenizerFile)
+		if err != nil {
+			log.Printf("Помилка завантаження токенізатора, використовуємо оцінку: %v", err)
+		} else {
+			api.SetTokenizer(tokenizer)
+		}
+	} else {
+		log.Printf("TOKENIZER_FILE не задано: токени контексту оцінюються наближено, із запасом")
+	}
+
+	if cfg.PricesFile != "" {
+		prices, err := api.LoadPrices(cfg.PricesFile)
+		if err != nil {
+			log.Printf("Помилка завантаження таблиці цін, використовуємо ціни за замовчуванням: %v", err)
+		} else {
+			api.SetPrices(prices)
+		}
+	}
+
+	myBot, err := bot.NewBot(cfg)
 	if err != nil {
 
 		log.Fatalf("Помилка створення бота: %v", err)
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	AnthropicBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion = "2023-06-01"
+
+	// Messages API вимагає явного ліміту довжини відповіді
+	anthropicMaxTokens = 4096
+)
+
+// Anthropic реалізує Provider поверх Anthropic Messages API.
+type Anthropic struct {
+	baseURL string
+	apiKey  string
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+// anthropicMessage - повідомлення Messages API. Content - рядок або масив
+// блоків, якщо повідомлення містить зображення.
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type anthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+func newAnthropicMessage(m Message) anthropicMessage {
+	if len(m.Images) == 0 {
+		return anthropicMessage{Role: m.Role, Content: m.Content}
+	}
+
+	blocks := make([]anthropicContentBlock, 0, len(m.Images)+1)
+	for _, image := range m.Images {
+		blocks = append(blocks, anthropicContentBlock{
+			Type: "image",
+			Source: &anthropicImageSource{
+				Type:      "base64",
+				MediaType: image.MediaType,
+				Data:      base64.StdEncoding.EncodeToString(image.Data),
+			},
+		})
+	}
+	if m.Content != "" {
+		blocks = append(blocks, anthropicContentBlock{Type: "text", Text: m.Content})
+	}
+	return anthropicMessage{Role: m.Role, Content: blocks}
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicStreamEvent - подія потокової відповіді. Токени запиту приходять
+// у message_start, токени відповіді - у message_delta.
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewAnthropic(apiKey string) *Anthropic {
+	return &Anthropic{
+		baseURL: AnthropicBaseURL,
+		apiKey:  apiKey,
+	}
+}
+
+func (a *Anthropic) Chat(ctx context.Context, model string, messages []Message) (*Completion, error) {
+	resp, err := a.postMessages(ctx, model, messages, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("помилка декодування відповіді: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("порожня відповідь від API")
+	}
+
+	return &Completion{
+		Content: text.String(),
+		Usage: Usage{
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+func (a *Anthropic) Stream(ctx context.Context, model string, messages []Message, onDelta func(delta string)) (*Completion, error) {
+	resp, err := a.postMessages(ctx, model, messages, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		full      strings.Builder
+		usage     Usage
+		streamErr error
+	)
+	err = readSSE(resp.Body, func(event, data string) bool {
+		var ev anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			log.Printf("Помилка декодування фрагмента: %v", err)
+			return true
+		}
+
+		switch ev.Type {
+		case "message_start":
+			usage.PromptTokens = ev.Message.Usage.InputTokens
+		case "message_delta":
+			usage.CompletionTokens = ev.Usage.OutputTokens
+		case "content_block_delta":
+			if ev.Delta.Type != "text_delta" || ev.Delta.Text == "" {
+				return true
+			}
+			full.WriteString(ev.Delta.Text)
+			if onDelta != nil {
+				onDelta(ev.Delta.Text)
+			}
+		case "error":
+			streamErr = fmt.Errorf("помилка API: %s", ev.Error.Message)
+			return false
+		case "message_stop":
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if streamErr != nil {
+		return nil, streamErr
+	}
+
+	if full.Len() == 0 {
+		return nil, fmt.Errorf("порожня відповідь від API")
+	}
+
+	return &Completion{Content: full.String(), Usage: usage}, nil
+}
+
+func (a *Anthropic) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", a.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+	a.setHeaders(req)
+
+	resp, err := doAPIRequest(http.DefaultClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Формат data[].id збігається з OpenAI
+	var response modelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("помилка декодування списку моделей: %w", err)
+	}
+
+	models := make([]string, 0, len(response.Data))
+	for _, m := range response.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+func (a *Anthropic) postMessages(ctx context.Context, model string, messages []Message, stream bool) (*http.Response, error) {
+	// Anthropic приймає системний промпт окремим полем, а не повідомленням
+	var (
+		system []string
+		turns  []anthropicMessage
+		last   string
+	)
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+		} else {
+			turns = append(turns, newAnthropicMessage(m))
+			last = m.Content
+		}
+	}
+
+	jsonData, err := json.Marshal(anthropicRequest{
+		Model:     model,
+		MaxTokens: anthropicMaxTokens,
+		System:    strings.Join(system, "\n\n"),
+		Messages:  turns,
+		Stream:    stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("помилка маршалінгу запиту: %w", err)
+	}
+
+	if len(turns) > 0 {
+		log.Printf("Anthropic запит: модель=%s, stream=%t, повідомлення = %s", model, stream, last)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	a.setHeaders(req)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return doAPIRequest(http.DefaultClient, req)
+}
+
+func (a *Anthropic) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Модель розпізнавання мовлення OpenAI
+	WhisperModel = "whisper-1"
+	// Модель синтезу мовлення OpenAI
+	SpeechModel = "tts-1"
+	// Максимальна довжина тексту для синтезу за один запит
+	MaxSpeechInput = 4096
+
+	// Ціна whisper-1 в доларах США за хвилину аудіо
+	transcriptionPricePerMinute = 0.006
+	// Ціна tts-1 в доларах США за 1 млн символів
+	speechPricePerMillion = 15.0
+	// Найбільший обсяг синтезованого аудіо, який читаємо з відповіді сервера
+	maxSpeechSize = 20 << 20
+)
+
+// Голоси синтезу мовлення OpenAI
+var SpeechVoices = []string{"alloy", "echo", "fable", "onyx", "nova", "shimmer"}
+
+// TranscriptionCost повертає вартість розпізнавання аудіо тривалістю seconds
+// в доларах США.
+func TranscriptionCost(seconds int) float64 {
+	return float64(seconds) / 60 * transcriptionPricePerMinute
+}
+
+// SpeechCost повертає вартість синтезу тексту text в доларах США.
+func SpeechCost(text string) float64 {
+	return float64(utf8.RuneCountInString(text)) * speechPricePerMillion / 1e6
+}
+
+// Transcriber - провайдер, що вміє розпізнавати мовлення
+type Transcriber interface {
+	// Transcribe повертає текст аудіофайлу. fileName потрібен серверу, щоб
+	// визначити формат (ogg, mp3, m4a тощо).
+	Transcribe(ctx context.Context, fileName string, audio io.Reader) (string, error)
+}
+
+// Speaker - провайдер, що вміє синтезувати мовлення
+type Speaker interface {
+	// Speak повертає аудіо OGG/Opus, яке Telegram приймає як голосове повідомлення.
+	Speak(ctx context.Context, text, voice string) ([]byte, error)
+}
+
+type speechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+type transcriptionResponse struct {
+	Text string `json:"text"`
+}
+
+func (o *OpenAICompatible) Transcribe(ctx context.Context, fileName string, audio io.Reader) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("model", WhisperModel); err != nil {
+		return "", fmt.Errorf("помилка формування запиту: %w", err)
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return "", fmt.Errorf("помилка формування запиту: %w", err)
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return "", fmt.Errorf("помилка читання аудіо: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("помилка формування запиту: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("помилка створення запиту: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	o.setHeaders(req)
+
+	resp, err := doAPIRequest(o.client, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response transcriptionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("помилка декодування відповіді: %w", err)
+	}
+
+	text := strings.TrimSpace(response.Text)
+	if text == "" {
+		return "", fmt.Errorf("не вдалося розпізнати мовлення")
+	}
+	return text, nil
+}
+
+func (o *OpenAICompatible) Speak(ctx context.Context, text, voice string) ([]byte, error) {
+	// Формат opus - це Opus у контейнері OGG, тож перекодування не потрібне
+	jsonData, err := json.Marshal(speechRequest{
+		Model:          SpeechModel,
+		Input:          text,
+		Voice:          voice,
+		ResponseFormat: "opus",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("помилка маршалінгу запиту: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/audio/speech", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	o.setHeaders(req)
+
+	resp, err := doAPIRequest(o.client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	audio, err := io.ReadAll(io.LimitReader(resp.Body, maxSpeechSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("помилка читання аудіо: %w", err)
+	}
+	if len(audio) > maxSpeechSize {
+		return nil, fmt.Errorf("сервер повернув завелике аудіо")
+	}
+	return audio, nil
+}
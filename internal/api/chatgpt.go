@@ -1,48 +1,115 @@
 package api
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
+	"strings"
 )
 
+// ChatGPT веде розмову користувача: тримає контекст, системний промпт і модель,
+// а запити виконує через обраний Provider. Сам ChatGPT теж реалізує Provider.
 type ChatGPT struct {
-	apiKey  string
-	model   string
-	context []chatMessage
+	provider     Provider
+	model        string
+	systemPrompt string
+	context      []Message
+
+	store          ContextStore
+	conversationID int64
+	loaded         bool
+	droppedTokens  int
+	lastUsage      Usage
+}
+
+// ContextStore зберігає контекст розмови між перезапусками бота
+type ContextStore interface {
+	LoadMessages(conversationID int64, limit int) ([]Message, error)
+	AppendMessages(conversationID int64, messages ...Message) error
+	ClearMessages(conversationID int64) error
 }
 
 const (
-	ModelGPT3 = "gpt-3.5-turbo"
-	ModelGPT4 = "gpt-4"
+	// Модель OpenAI для нових користувачів
+	DefaultOpenAIModel = "gpt-4o-mini"
+
+	// Скільки повідомлень піднімати зі сховища; далі контекст обрізається за токенами
+	maxContextMessages = 100
 )
 
-type chatRequest struct {
-	Model    string        `json:"model"`
-	Messages []chatMessage `json:"messages"`
+// Message - повідомлення розмови у форматі OpenAI (system, user або assistant)
+type Message struct {
+	Role    string  `json:"role"`
+	Content string  `json:"content"`
+	Images  []Image `json:"-"`
 }
 
-type chatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+// NewChatGPT створює розмову з OpenAI API.
+func NewChatGPT(apiKey string) *ChatGPT {
+	return NewChatGPTWithProvider(NewOpenAI(apiKey))
 }
 
-type chatResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+// NewChatGPTWithProvider створює розмову поверх довільного провайдера.
+func NewChatGPTWithProvider(provider Provider) *ChatGPT {
+	return &ChatGPT{
+		provider: provider,
+		model:    DefaultOpenAIModel,
+	}
 }
 
-func NewChatGPT(apiKey string) *ChatGPT {
-	return &ChatGPT{
-		apiKey: apiKey,
-		model:  ModelGPT3,
+func (c *ChatGPT) Chat(ctx context.Context, model string, messages []Message) (*Completion, error) {
+	return c.provider.Chat(ctx, model, messages)
+}
+
+func (c *ChatGPT) Stream(ctx context.Context, model string, messages []Message, onDelta func(delta string)) (*Completion, error) {
+	return c.provider.Stream(ctx, model, messages, onDelta)
+}
+
+func (c *ChatGPT) ListModels(ctx context.Context) ([]string, error) {
+	return c.provider.ListModels(ctx)
+}
+
+// Transcribe розпізнає мовлення, якщо провайдер це підтримує.
+func (c *ChatGPT) Transcribe(ctx context.Context, fileName string, audio io.Reader) (string, error) {
+	transcriber, ok := c.provider.(Transcriber)
+	if !ok {
+		return "", fmt.Errorf("провайдер не підтримує розпізнавання мовлення")
+	}
+	return transcriber.Transcribe(ctx, fileName, audio)
+}
+
+// SupportsSpeech повідомляє, чи вміє провайдер синтезувати мовлення.
+func (c *ChatGPT) SupportsSpeech() bool {
+	_, ok := c.provider.(Speaker)
+	return ok
+}
+
+// Speak синтезує мовлення, якщо провайдер це підтримує.
+func (c *ChatGPT) Speak(ctx context.Context, text, voice string) ([]byte, error) {
+	speaker, ok := c.provider.(Speaker)
+	if !ok {
+		return nil, fmt.Errorf("провайдер не підтримує синтез мовлення")
 	}
+	return speaker.Speak(ctx, text, voice)
+}
+
+// GenerateImage генерує зображення, якщо провайдер це підтримує.
+func (c *ChatGPT) GenerateImage(ctx context.Context, prompt, size, quality string) (*GeneratedImage, error) {
+	generator, ok := c.provider.(ImageGenerator)
+	if !ok {
+		return nil, fmt.Errorf("провайдер не підтримує генерацію зображень")
+	}
+	return generator.GenerateImage(ctx, prompt, size, quality)
+}
+
+// Embed рахує ембединги, якщо провайдер це підтримує.
+func (c *ChatGPT) Embed(ctx context.Context, model string, inputs []string) ([][]float32, Usage, error) {
+	embedder, ok := c.provider.(Embedder)
+	if !ok {
+		return nil, Usage{}, fmt.Errorf("провайдер не підтримує ембединги")
+	}
+	return embedder.Embed(ctx, model, inputs)
 }
 
 func (c *ChatGPT) SetModel(model string) {
@@ -53,64 +120,229 @@ func (c *ChatGPT) GetModel() string {
 	return c.model
 }
 
-func (c *ChatGPT) SendMessage(prompt string) (string, error) {
-	c.context = append(c.context, chatMessage{Role: "user", Content: prompt})
+// SetSystemPrompt задає системний промпт, який додається на початок кожного запиту.
+func (c *ChatGPT) SetSystemPrompt(prompt string) {
+	c.systemPrompt = prompt
+}
 
-	if len(c.context) > 10 {
-		c.context = c.context[len(c.context)-10:]
-	}
+func (c *ChatGPT) GetSystemPrompt() string {
+	return c.systemPrompt
+}
+
+// DroppedTokens повертає кількість токенів старої історії, які не вмістилися
+// у контекстне вікно під час останнього запиту.
+func (c *ChatGPT) DroppedTokens() int {
+	return c.droppedTokens
+}
+
+// LastUsage повертає кількість токенів останнього запиту. Якщо провайдер не
+// повідомив usage (деякі OpenAI-сумісні сервери), токени оцінюються локально.
+func (c *ChatGPT) LastUsage() Usage {
+	return c.lastUsage
+}
+
+// ConversationID повертає id збереженої розмови, до якої прив'язано екземпляр.
+func (c *ChatGPT) ConversationID() int64 {
+	return c.conversationID
+}
+
+// SetStore прив'язує екземпляр до збереженої розмови. Контекст буде
+// завантажено зі сховища під час першого запиту.
+func (c *ChatGPT) SetStore(store ContextStore, conversationID int64) {
+	c.store = store
+	c.conversationID = conversationID
+	c.context = nil
+	c.loaded = false
+}
 
-	reqBody := chatRequest{
-		Model:    c.model,
-		Messages: c.context,
+func (c *ChatGPT) loadContext() {
+	if c.loaded || c.store == nil {
+		return
 	}
+	c.loaded = true
 
-	jsonData, err := json.Marshal(reqBody)
+	messages, err := c.store.LoadMessages(c.conversationID, maxContextMessages)
 	if err != nil {
-		return "", fmt.Errorf("помилка маршалінгу запиту: %w", err)
+		log.Printf("Помилка завантаження контексту розмови %d: %v", c.conversationID, err)
+		return
 	}
+	c.context = append(messages, c.context...)
+}
 
-	if len(c.context) > 0 {
-		lastMsg := c.context[len(c.context)-1]
-		log.Printf("OpenAI запит: модель=%s, повідомлення = %s", c.model, lastMsg.Content)
+// saveTurn зберігає запит і відповідь у сховищі після успішної відповіді.
+func (c *ChatGPT) saveTurn(prompt, response string) {
+	if c.store == nil {
+		return
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	err := c.store.AppendMessages(c.conversationID,
+		Message{Role: "user", Content: prompt},
+		Message{Role: "assistant", Content: response},
+	)
+	if err != nil {
+		log.Printf("Помилка збереження контексту розмови %d: %v", c.conversationID, err)
+	}
+}
+
+func (c *ChatGPT) SendMessage(prompt string) (string, error) {
+	message := Message{Role: "user", Content: prompt}
+	messages := c.prepareMessages(c.model, message, "")
+	completion, err := c.provider.Chat(context.Background(), c.model, messages)
 	if err != nil {
-		return "", fmt.Errorf("помилка створення запиту: %w", err)
+		c.rollbackTurn()
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	c.finishTurn(message, messages, completion)
+	return completion.Content, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// SendMessageStream надсилає запит у потоковому режимі і викликає onDelta для
+// кожного отриманого фрагмента тексту. Повертає повну відповідь після
+// завершення потоку.
+func (c *ChatGPT) SendMessageStream(prompt string, onDelta func(delta string)) (string, error) {
+	return c.sendStream(c.model, Message{Role: "user", Content: prompt}, "", onDelta)
+}
+
+// SendGroundedStream працює як SendMessageStream, але додає до запиту
+// довідковий матеріал (наприклад, фрагменти документа). Матеріал
+// надсилається лише в цьому запиті і не зберігається в історії.
+func (c *ChatGPT) SendGroundedStream(prompt, grounding string, onDelta func(delta string)) (string, error) {
+	return c.sendStream(c.model, Message{Role: "user", Content: prompt}, grounding, onDelta)
+}
+
+// SendImageStream надсилає зображення з підписом у потоковому режимі моделі
+// model, яка має підтримувати зображення (див. VisionModel).
+func (c *ChatGPT) SendImageStream(model, prompt string, images []Image, onDelta func(delta string)) (string, error) {
+	return c.sendStream(model, Message{Role: "user", Content: prompt, Images: images}, "", onDelta)
+}
+
+func (c *ChatGPT) sendStream(model string, message Message, grounding string, onDelta func(delta string)) (string, error) {
+	messages := c.prepareMessages(model, message, grounding)
+	completion, err := c.provider.Stream(context.Background(), model, messages, onDelta)
 	if err != nil {
-		return "", fmt.Errorf("помилка виконання запиту: %w", err)
+		c.rollbackTurn()
+		return "", err
+	}
+
+	c.finishTurn(message, messages, completion)
+	return completion.Content, nil
+}
+
+// EstimatePromptTokens оцінює кількість токенів запиту з prompt разом з
+// системним промптом та історією, не змінюючи контекст розмови.
+func (c *ChatGPT) EstimatePromptTokens(prompt string, images ...Image) int {
+	return c.EstimatePromptTokensFor(c.model, prompt, images...)
+}
+
+// EstimatePromptTokensFor працює як EstimatePromptTokens, але обрізає історію
+// під контекстне вікно моделі model, якою буде виконано запит.
+func (c *ChatGPT) EstimatePromptTokensFor(model, prompt string, images ...Image) int {
+	c.loadContext()
+
+	messages := make([]Message, 0, len(c.context)+2)
+	if c.systemPrompt != "" {
+		messages = append(messages, Message{Role: "system", Content: c.systemPrompt})
+	}
+	messages = append(messages, c.context...)
+	messages = append(messages, Message{Role: "user", Content: prompt, Images: images})
+
+	messages, _ = fitToBudget(messages, ContextBudget(model))
+	return CountMessageTokens(messages)
+}
+
+// prepareMessages додає повідомлення до контексту і збирає повідомлення запиту
+// з системним промптом, обрізаючи історію під контекстне вікно моделі.
+// Непорожній grounding додається системним повідомленням перед запитом.
+func (c *ChatGPT) prepareMessages(model string, message Message, grounding string) []Message {
+	c.loadContext()
+	c.context = append(c.context, message)
+
+	messages := c.context
+	if c.systemPrompt != "" {
+		messages = append([]Message{{Role: "system", Content: c.systemPrompt}}, c.context...)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("помилка API (код %d): %s", resp.StatusCode, string(body))
+	var groundingMessage Message
+	budget := ContextBudget(model)
+	if grounding != "" {
+		groundingMessage = Message{Role: "system", Content: grounding}
+		budget -= CountMessageTokens([]Message{groundingMessage})
 	}
 
-	var response chatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("помилка декодування відповіді: %w", err)
+	messages, c.droppedTokens = fitToBudget(messages, budget)
+	if c.droppedTokens > 0 {
+		log.Printf("Контекст обрізано: модель=%s, відкинуто токенів=%d", model, c.droppedTokens)
+	}
+	if c.systemPrompt != "" {
+		c.context = messages[1:]
+	} else {
+		c.context = messages
 	}
 
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("порожня відповідь від API")
+	if grounding != "" {
+		request := make([]Message, 0, len(messages)+1)
+		request = append(request, messages[:len(messages)-1]...)
+		request = append(request, groundingMessage, messages[len(messages)-1])
+		return request
 	}
+	return messages
+}
 
-	c.context = append(c.context, chatMessage{
+// rollbackTurn прибирає з контексту запит, на який модель не відповіла, щоб
+// він (разом із зображеннями) не потрапив у наступні запити.
+func (c *ChatGPT) rollbackTurn() {
+	if n := len(c.context); n > 0 {
+		c.context = c.context[:n-1]
+	}
+}
+
+func (c *ChatGPT) finishTurn(message Message, messages []Message, completion *Completion) {
+	// Зображення надсилаються лише в запиті, до якого їх додали; в історії
+	// лишається позначка, щоб не платити за них у кожному наступному запиті
+	prompt := message.Content
+	if len(message.Images) > 0 {
+		prompt = strings.TrimSpace("[зображення] " + prompt)
+		for i := len(c.context) - 1; i >= 0; i-- {
+			if len(c.context[i].Images) > 0 {
+				c.context[i] = Message{Role: c.context[i].Role, Content: prompt}
+				break
+			}
+		}
+	}
+
+	c.lastUsage = completion.Usage
+	if c.lastUsage.Total() == 0 {
+		c.lastUsage = Usage{
+			PromptTokens:     CountMessageTokens(messages),
+			CompletionTokens: CountTokens(completion.Content),
+		}
+	}
+
+	c.context = append(c.context, Message{
 		Role:    "assistant",
-		Content: response.Choices[0].Message.Content,
+		Content: completion.Content,
 	})
+	c.saveTurn(prompt, completion.Content)
+}
 
-	return response.Choices[0].Message.Content, nil
+// ResetContext скидає контекст у пам'яті, не чіпаючи збережених повідомлень:
+// під час наступного запиту він знову завантажиться зі сховища.
+func (c *ChatGPT) ResetContext() {
+	c.context = nil
+	c.loaded = false
+	c.droppedTokens = 0
 }
 
+// ClearContext видаляє контекст розмови і в пам'яті, і у сховищі.
 func (c *ChatGPT) ClearContext() {
 	c.context = nil
+	c.loaded = true
+	c.droppedTokens = 0
+
+	if c.store != nil {
+		if err := c.store.ClearMessages(c.conversationID); err != nil {
+			log.Printf("Помилка очищення контексту розмови %d: %v", c.conversationID, err)
+		}
+	}
 }
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Embedder - провайдер, що вміє рахувати ембединги тексту
+type Embedder interface {
+	// Embed повертає по одному вектору на кожен рядок inputs у тому ж порядку
+	// і кількість витрачених токенів.
+	Embed(ctx context.Context, model string, inputs []string) ([][]float32, Usage, error)
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// EmbeddingModel повертає модель ембедингів провайдера або "", якщо
+// провайдер їх не підтримує чи модель невідома.
+func EmbeddingModel(provider string) string {
+	switch provider {
+	case "", ProviderOpenAI:
+		return "text-embedding-3-small"
+	case ProviderOllama:
+		return "nomic-embed-text"
+	default:
+		return ""
+	}
+}
+
+func (o *OpenAICompatible) Embed(ctx context.Context, model string, inputs []string) ([][]float32, Usage, error) {
+	jsonData, err := json.Marshal(embeddingRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("помилка маршалінгу запиту: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	o.setHeaders(req)
+
+	resp, err := doAPIRequest(o.client, req)
+	if err != nil {
+		return nil, Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var response embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, Usage{}, fmt.Errorf("помилка декодування відповіді: %w", err)
+	}
+	if len(response.Data) != len(inputs) {
+		return nil, Usage{}, fmt.Errorf("отримано %d ембедингів замість %d", len(response.Data), len(inputs))
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, Usage{}, fmt.Errorf("некоректний індекс ембединга: %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+
+	return vectors, response.Usage.toUsage(), nil
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Скільки байтів тіла помилки читати: сервер користувача може повернути що завгодно
+const maxErrorBody = 64 * 1024
+
+// APIError - відповідь провайдера з кодом, відмінним від 200
+type APIError struct {
+	StatusCode int
+	Type       string // error.type або error.code з тіла відповіді, якщо є
+	Message    string
+	Body       string // сире тіло відповіді лише для журналу, користувачам не показується
+}
+
+// Error показує лише розібране повідомлення провайдера: сире тіло може
+// містити внутрішні дані сервера, а текст помилки бачить користувач.
+func (e *APIError) Error() string {
+	switch {
+	case e.Message != "":
+		return fmt.Sprintf("помилка API (код %d): %s", e.StatusCode, e.Message)
+	case e.Type != "":
+		return fmt.Sprintf("помилка API (код %d): %s", e.StatusCode, e.Type)
+	default:
+		return fmt.Sprintf("помилка API (код %d): %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+}
+
+// newAPIError розбирає тіло помилки у форматі OpenAI або Anthropic:
+// {"error": {"type": "...", "code": "...", "message": "..."}}
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: string(body)}
+
+	var parsed struct {
+		Error struct {
+			Type    string `json:"type"`
+			Code    any    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		apiErr.Type = parsed.Error.Type
+		if code, ok := parsed.Error.Code.(string); ok && code != "" {
+			apiErr.Type = code
+		}
+		apiErr.Message = parsed.Error.Message
+	}
+
+	return apiErr
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// Модель генерації зображень OpenAI
+	ImageModel = "dall-e-3"
+
+	ImageQualityStandard = "standard"
+	ImageQualityHD       = "hd"
+)
+
+// Розміри, які підтримує dall-e-3
+var ImageSizes = []string{"1024x1024", "1792x1024", "1024x1792"}
+
+// Ціни dall-e-3 в доларах США за одне зображення
+var imagePrices = map[string]map[string]float64{
+	ImageQualityStandard: {"1024x1024": 0.04, "1792x1024": 0.08, "1024x1792": 0.08},
+	ImageQualityHD:       {"1024x1024": 0.08, "1792x1024": 0.12, "1024x1792": 0.12},
+}
+
+// ImageGenerator - провайдер, що вміє генерувати зображення
+type ImageGenerator interface {
+	GenerateImage(ctx context.Context, prompt, size, quality string) (*GeneratedImage, error)
+}
+
+// GeneratedImage - результат генерації зображення
+type GeneratedImage struct {
+	Data          []byte // PNG
+	RevisedPrompt string // запит, уточнений моделлю перед генерацією
+}
+
+type imageRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n"`
+	Size           string `json:"size"`
+	Quality        string `json:"quality"`
+	ResponseFormat string `json:"response_format"`
+}
+
+type imageResponse struct {
+	Data []struct {
+		B64JSON       string `json:"b64_json"`
+		RevisedPrompt string `json:"revised_prompt"`
+	} `json:"data"`
+}
+
+// ImageCost повертає вартість одного зображення в доларах США.
+func ImageCost(size, quality string) float64 {
+	return imagePrices[quality][size]
+}
+
+func (o *OpenAICompatible) GenerateImage(ctx context.Context, prompt, size, quality string) (*GeneratedImage, error) {
+	jsonData, err := json.Marshal(imageRequest{
+		Model:          ImageModel,
+		Prompt:         prompt,
+		N:              1,
+		Size:           size,
+		Quality:        quality,
+		ResponseFormat: "b64_json",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("помилка маршалінгу запиту: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/images/generations", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	o.setHeaders(req)
+
+	resp, err := doAPIRequest(o.client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response imageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("помилка декодування відповіді: %w", err)
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("порожня відповідь від API")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(response.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("помилка декодування зображення: %w", err)
+	}
+	return &GeneratedImage{Data: data, RevisedPrompt: response.Data[0].RevisedPrompt}, nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// KeyStatus - результат перевірки API ключа
+type KeyStatus int
+
+const (
+	KeyValid KeyStatus = iota
+	KeyInvalid
+	KeyNoQuota
+	KeyOrgRestricted
+	KeyUnverified // провайдер недоступний або повернув неочікувану помилку
+)
+
+// ValidateKey перевіряє ключ дешевим авторизованим запитом списку моделей.
+// Повертає також помилку провайдера для логування.
+func ValidateKey(ctx context.Context, provider Provider) (KeyStatus, error) {
+	_, err := provider.ListModels(ctx)
+	if err == nil {
+		return KeyValid, nil
+	}
+	return ClassifyKeyError(err), err
+}
+
+// ClassifyKeyError визначає за помилкою API, що не так з ключем.
+func ClassifyKeyError(err error) KeyStatus {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return KeyUnverified
+	}
+
+	switch {
+	case apiErr.Type == "insufficient_quota" || apiErr.Type == "billing_not_active":
+		return KeyNoQuota
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.Type == "invalid_api_key" || apiErr.Type == "authentication_error":
+		return KeyInvalid
+	case apiErr.StatusCode == http.StatusForbidden || apiErr.Type == "permission_error" ||
+		strings.Contains(strings.ToLower(apiErr.Message), "organization"):
+		return KeyOrgRestricted
+	case apiErr.StatusCode == http.StatusPaymentRequired:
+		return KeyNoQuota
+	default:
+		return KeyUnverified
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Людські назви відомих моделей; для решти показуємо ідентифікатор як є
+var modelDisplayNames = map[string]string{
+	"gpt-3.5-turbo":            "GPT-3.5",
+	"gpt-4":                    "GPT-4",
+	"gpt-4-turbo":              "GPT-4 Turbo",
+	"gpt-4o":                   "GPT-4o",
+	"gpt-4o-mini":              "GPT-4o mini",
+	"o1":                       "o1",
+	"o1-mini":                  "o1 mini",
+	"o3-mini":                  "o3 mini",
+	"claude-3-5-sonnet-latest": "Claude 3.5 Sonnet",
+	"claude-3-5-haiku-latest":  "Claude 3.5 Haiku",
+	"claude-3-opus-latest":     "Claude 3 Opus",
+}
+
+// Частини ідентифікаторів моделей, які не вміють вести чат
+var nonChatModelMarkers = []string{
+	"embedding", "whisper", "tts", "dall-e", "moderation", "davinci",
+	"babbage", "transcribe", "realtime", "audio", "search", "instruct",
+}
+
+// ModelDisplayName повертає назву моделі для показу користувачу.
+func ModelDisplayName(model string) string {
+	if name, ok := modelDisplayNames[model]; ok {
+		return name
+	}
+	if model == "" {
+		return "не обрано"
+	}
+	return model
+}
+
+// IsChatModel відсіює моделі ембедингів, аудіо та зображень зі списку /v1/models.
+func IsChatModel(model string) bool {
+	for _, marker := range nonChatModelMarkers {
+		if strings.Contains(model, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// ModelCatalog кешує списки моделей, доступних з кожним ключем, на ttl.
+type ModelCatalog struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]catalogEntry
+}
+
+type catalogEntry struct {
+	models    []string
+	fetchedAt time.Time
+}
+
+func NewModelCatalog(ttl time.Duration) *ModelCatalog {
+	return &ModelCatalog{
+		ttl:     ttl,
+		entries: make(map[string]catalogEntry),
+	}
+}
+
+// Models повертає відсортований список чат-моделей провайдера. key має
+// однозначно визначати ключ і сервер; у кеші зберігається лише його хеш.
+func (m *ModelCatalog) Models(ctx context.Context, key string, provider Provider) ([]string, error) {
+	sum := sha256.Sum256([]byte(key))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	m.mu.Lock()
+	entry, ok := m.entries[cacheKey]
+	m.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < m.ttl {
+		return entry.models, nil
+	}
+
+	all, err := provider.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(all))
+	for _, model := range all {
+		if IsChatModel(model) {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+
+	m.mu.Lock()
+	m.entries[cacheKey] = catalogEntry{models: models, fetchedAt: time.Now()}
+	m.mu.Unlock()
+
+	return models, nil
+}
+
+// Invalidate видаляє кешований список для ключа.
+func (m *ModelCatalog) Invalidate(key string) {
+	sum := sha256.Sum256([]byte(key))
+
+	m.mu.Lock()
+	delete(m.entries, hex.EncodeToString(sum[:]))
+	m.mu.Unlock()
+}
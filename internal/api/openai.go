@@ -0,0 +1,277 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	OpenAIBaseURL = "https://api.openai.com/v1"
+	OllamaBaseURL = "http://localhost:11434/v1"
+)
+
+// OpenAICompatible працює з будь-яким сервером, що реалізує OpenAI Chat
+// Completions API: OpenAI, llama.cpp, vLLM, Ollama тощо.
+type OpenAICompatible struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+// streamOptions просить надіслати usage останнім фрагментом потоку
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *openAIUsage) toUsage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// chatStreamChunk - один SSE-фрагмент відповіді при stream: true
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func NewOpenAI(apiKey string) *OpenAICompatible {
+	return NewOpenAICompatible(OpenAIBaseURL, apiKey)
+}
+
+func NewOpenAICompatible(baseURL, apiKey string) *OpenAICompatible {
+	return &OpenAICompatible{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  http.DefaultClient,
+	}
+}
+
+// PublicOnly забороняє з'єднання з локальними та приватними адресами.
+// Використовується для серверів, адресу яких вказав користувач.
+func (o *OpenAICompatible) PublicOnly() *OpenAICompatible {
+	o.client = publicHTTPClient
+	return o
+}
+
+func (o *OpenAICompatible) Chat(ctx context.Context, model string, messages []Message) (*Completion, error) {
+	resp, err := o.postChat(ctx, model, messages, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("помилка декодування відповіді: %w", err)
+	}
+
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("порожня відповідь від API")
+	}
+
+	return &Completion{
+		Content: response.Choices[0].Message.Content,
+		Usage:   response.Usage.toUsage(),
+	}, nil
+}
+
+func (o *OpenAICompatible) Stream(ctx context.Context, model string, messages []Message, onDelta func(delta string)) (*Completion, error) {
+	resp, err := o.postChat(ctx, model, messages, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		full  strings.Builder
+		usage Usage
+	)
+	err = readSSE(resp.Body, func(event, data string) bool {
+		if data == "[DONE]" {
+			return false
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			log.Printf("Помилка декодування фрагмента: %v", err)
+			return true
+		}
+
+		// Фрагмент з usage приходить останнім і не містить choices
+		if chunk.Usage != nil {
+			usage = chunk.Usage.toUsage()
+		}
+
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return true
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		full.WriteString(delta)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if full.Len() == 0 {
+		return nil, fmt.Errorf("порожня відповідь від API")
+	}
+
+	return &Completion{Content: full.String(), Usage: usage}, nil
+}
+
+func (o *OpenAICompatible) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", o.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+	o.setHeaders(req)
+
+	resp, err := doAPIRequest(o.client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response modelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("помилка декодування списку моделей: %w", err)
+	}
+
+	models := make([]string, 0, len(response.Data))
+	for _, m := range response.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+func (o *OpenAICompatible) postChat(ctx context.Context, model string, messages []Message, stream bool) (*http.Response, error) {
+	request := chatRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   stream,
+	}
+	if stream {
+		request.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("помилка маршалінгу запиту: %w", err)
+	}
+
+	if len(messages) > 0 {
+		lastMsg := messages[len(messages)-1]
+		log.Printf("OpenAI запит: %s, модель=%s, stream=%t, повідомлення = %s", o.baseURL, model, stream, lastMsg.Content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	o.setHeaders(req)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return doAPIRequest(o.client, req)
+}
+
+func (o *OpenAICompatible) setHeaders(req *http.Request) {
+	// Локальні сервери часто працюють без ключа
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+}
+
+// doAPIRequest виконує запит клієнтом client і перетворює відповідь з кодом, відмінним від 200,
+// на помилку. Тіло успішної відповіді закриває викликач.
+func doAPIRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("помилка виконання запиту: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		log.Printf("Помилка API %s (код %d): %s", req.URL.Host, resp.StatusCode, body)
+		return nil, newAPIError(resp.StatusCode, body)
+	}
+
+	return resp, nil
+}
+
+// readSSE читає потік server-sent events і викликає handle для кожної події
+// з даними. Якщо handle повертає false, читання припиняється.
+func readSSE(body io.Reader, handle func(event, data string) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if !handle(event, data) {
+				return nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("помилка читання потоку: %w", err)
+	}
+	return nil
+}
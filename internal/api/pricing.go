@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ModelPrice - ціна моделі в доларах США за 1 млн токенів
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Ціни за замовчуванням; локальні моделі (Ollama тощо) не мають ціни і
+// рахуються безкоштовними
+var defaultModelPrices = map[string]ModelPrice{
+	"gpt-3.5-turbo":      {Input: 0.50, Output: 1.50},
+	"gpt-4":              {Input: 30, Output: 60},
+	"gpt-4-32k":          {Input: 60, Output: 120},
+	"gpt-4-turbo":        {Input: 10, Output: 30},
+	"gpt-4-1106-preview": {Input: 10, Output: 30},
+	"gpt-4-0125-preview": {Input: 10, Output: 30},
+	"gpt-4o":             {Input: 2.50, Output: 10},
+	"gpt-4o-2024-05-13":  {Input: 5, Output: 15},
+	"chatgpt-4o-latest":  {Input: 5, Output: 15},
+	"gpt-4o-mini":        {Input: 0.15, Output: 0.60},
+	"gpt-4.1":            {Input: 2, Output: 8},
+	"gpt-4.1-mini":       {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":       {Input: 0.10, Output: 0.40},
+	"gpt-5":              {Input: 1.25, Output: 10},
+	"gpt-5-mini":         {Input: 0.25, Output: 2},
+	"gpt-5-nano":         {Input: 0.05, Output: 0.40},
+	"o1":                 {Input: 15, Output: 60},
+	"o1-mini":            {Input: 3, Output: 12},
+	"o3":                 {Input: 2, Output: 8},
+	"o3-mini":            {Input: 1.10, Output: 4.40},
+	"o4-mini":            {Input: 1.10, Output: 4.40},
+	"claude-3-haiku":     {Input: 0.25, Output: 1.25},
+	"claude-3-sonnet":    {Input: 3, Output: 15},
+	"claude-3-opus":      {Input: 15, Output: 75},
+	"claude-3-5-haiku":   {Input: 0.80, Output: 4},
+	"claude-3-5-sonnet":  {Input: 3, Output: 15},
+	"claude-3-7-sonnet":  {Input: 3, Output: 15},
+	"claude-sonnet-4":    {Input: 3, Output: 15},
+	"claude-sonnet-4-5":  {Input: 3, Output: 15},
+	"claude-opus-4":      {Input: 15, Output: 75},
+	"claude-opus-4-1":    {Input: 15, Output: 75},
+	"claude-haiku-4-5":   {Input: 1, Output: 5},
+
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+}
+
+var (
+	pricesMu    sync.RWMutex
+	modelPrices = defaultModelPrices
+)
+
+// LoadPrices читає таблицю цін з JSON-файлу виду
+// {"gpt-4o": {"input": 2.5, "output": 10}}.
+func LoadPrices(path string) (map[string]ModelPrice, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("помилка читання таблиці цін: %w", err)
+	}
+
+	var prices map[string]ModelPrice
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("помилка розбору таблиці цін: %w", err)
+	}
+	return prices, nil
+}
+
+// SetPrices доповнює ціни за замовчуванням цінами з конфігурації.
+func SetPrices(prices map[string]ModelPrice) {
+	merged := make(map[string]ModelPrice, len(defaultModelPrices)+len(prices))
+	for name, price := range defaultModelPrices {
+		merged[name] = price
+	}
+	for name, price := range prices {
+		merged[name] = price
+	}
+
+	pricesMu.Lock()
+	modelPrices = merged
+	pricesMu.Unlock()
+}
+
+// PriceFor повертає ціну моделі. Версійні назви (gpt-4o-2024-08-06)
+// шукаємо за найдовшою назвою, яка відповідає моделі з точністю до версії.
+func PriceFor(model string) (ModelPrice, bool) {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+
+	var (
+		price   ModelPrice
+		matched int
+	)
+	for name, p := range modelPrices {
+		if matchesModel(model, name) && len(name) > matched {
+			price, matched = p, len(name)
+		}
+	}
+	return price, matched > 0
+}
+
+// matchesModel перевіряє, чи є model назвою name або її версією:
+// gpt-4o-2024-08-06, claude-3-5-sonnet-latest, llama3.1:8b. Інші моделі
+// з тим самим префіксом (gpt-4o-mini для gpt-4o, gpt-4.1 для gpt-4) не
+// підходять.
+func matchesModel(model, name string) bool {
+	suffix, ok := strings.CutPrefix(model, name)
+	if !ok {
+		return false
+	}
+	switch {
+	case suffix == "" || strings.HasPrefix(suffix, ":"):
+		return true
+	case strings.HasPrefix(suffix, "-"):
+		version := suffix[1:]
+		return strings.HasPrefix(version, "latest") || strings.HasPrefix(version, "preview") ||
+			(version != "" && version[0] >= '0' && version[0] <= '9')
+	default:
+		return false
+	}
+}
+
+// EstimateCost оцінює вартість запиту в доларах США.
+func EstimateCost(model string, usage Usage) float64 {
+	price, ok := PriceFor(model)
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+// Провайдери, які користувач може обрати в налаштуваннях
+const (
+	ProviderOpenAI     = "openai"
+	ProviderCompatible = "openai_compatible"
+	ProviderOllama     = "ollama"
+	ProviderAnthropic  = "anthropic"
+)
+
+// Provider - бекенд LLM, до якого бот надсилає запити
+type Provider interface {
+	// Chat повертає повну відповідь моделі на список повідомлень.
+	Chat(ctx context.Context, model string, messages []Message) (*Completion, error)
+	// Stream викликає onDelta для кожного фрагмента відповіді і повертає повну відповідь.
+	Stream(ctx context.Context, model string, messages []Message, onDelta func(delta string)) (*Completion, error)
+	// ListModels повертає ідентифікатори моделей, доступних з цим ключем.
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// Completion - результат запиту до моделі
+type Completion struct {
+	Content string
+	Usage   Usage
+}
+
+// Usage - кількість токенів запиту і відповіді за даними провайдера
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// NewProvider створює провайдера за назвою з налаштувань користувача.
+// baseURL використовується лише для OpenAI-сумісних серверів.
+func NewProvider(name, apiKey, baseURL string) (Provider, error) {
+	switch name {
+	case "", ProviderOpenAI:
+		return NewOpenAI(apiKey), nil
+	case ProviderCompatible:
+		if baseURL == "" {
+			return nil, fmt.Errorf("не вказано адресу OpenAI-сумісного сервера")
+		}
+		return NewOpenAICompatible(baseURL, apiKey), nil
+	case ProviderOllama:
+		if baseURL == "" {
+			baseURL = OllamaBaseURL
+		}
+		return NewOpenAICompatible(baseURL, apiKey), nil
+	case ProviderAnthropic:
+		return NewAnthropic(apiKey), nil
+	default:
+		return nil, fmt.Errorf("невідомий провайдер: %s", name)
+	}
+}
+
+// DefaultModel повертає модель, яка встановлюється після зміни провайдера.
+func DefaultModel(provider string) string {
+	switch provider {
+	case ProviderAnthropic:
+		return "claude-3-5-sonnet-latest"
+	case ProviderOllama:
+		return "llama3.1"
+	case ProviderCompatible:
+		return ""
+	default:
+		return DefaultOpenAIModel
+	}
+}
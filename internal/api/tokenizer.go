@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Правила попереднього розбиття тексту з cl100k_base (без lookahead,
+// якого немає в regexp з stdlib)
+var pretokenizePattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+const (
+	// Службові токени на кожне повідомлення і на початок відповіді
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+)
+
+// Tokenizer рахує токени byte-level BPE локально, без звернень до мережі.
+// Без словника рангів (TOKENIZER_FILE) це лише оцінка для cl100k_base,
+// свідомо завищена, щоб запит гарантовано вмістився у вікно моделі.
+type Tokenizer struct {
+	ranks map[string]int
+}
+
+var (
+	tokenizerMu      sync.RWMutex
+	defaultTokenizer = &Tokenizer{}
+)
+
+// LoadTokenizer завантажує ранги злиттів у форматі tiktoken
+// (рядки "<base64 токен> <ранг>", наприклад cl100k_base.tiktoken).
+func LoadTokenizer(path string) (*Tokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("помилка відкриття словника токенізатора: %w", err)
+	}
+	defer file.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) != 2 {
+			continue
+		}
+
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("некоректний токен у словнику: %w", err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("некоректний ранг у словнику: %w", err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("помилка читання словника токенізатора: %w", err)
+	}
+
+	return &Tokenizer{ranks: ranks}, nil
+}
+
+// SetTokenizer встановлює токенізатор для підрахунку контексту.
+func SetTokenizer(t *Tokenizer) {
+	tokenizerMu.Lock()
+	defaultTokenizer = t
+	tokenizerMu.Unlock()
+}
+
+// CountTokens рахує токени тексту поточним токенізатором.
+func CountTokens(text string) int {
+	tokenizerMu.RLock()
+	t := defaultTokenizer
+	tokenizerMu.RUnlock()
+	return t.Count(text)
+}
+
+// CountMessageTokens рахує токени запиту з урахуванням службових токенів чату.
+func CountMessageTokens(messages []Message) int {
+	total := tokensPerReply
+	for _, m := range messages {
+		total += tokensPerMessage + CountTokens(m.Role) + CountTokens(m.Content) + len(m.Images)*imageTokens
+	}
+	return total
+}
+
+func (t *Tokenizer) Count(text string) int {
+	count := 0
+	for _, piece := range pretokenizePattern.FindAllString(text, -1) {
+		if t.ranks == nil {
+			count += estimatePieceTokens(piece)
+		} else {
+			count += t.encodePiece(piece)
+		}
+	}
+	return count
+}
+
+// encodePiece виконує злиття BPE за рангами і повертає кількість токенів.
+func (t *Tokenizer) encodePiece(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+
+	for len(parts) > 1 {
+		bestRank, bestIdx := -1, -1
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && (bestRank == -1 || rank < bestRank) {
+				bestRank, bestIdx = rank, i
+			}
+		}
+		if bestIdx == -1 {
+			break
+		}
+
+		parts[bestIdx] += parts[bestIdx+1]
+		parts = append(parts[:bestIdx+1], parts[bestIdx+2:]...)
+	}
+
+	return len(parts)
+}
+
+// Вага символів для оцінки без словника, у дванадцятих частках токена.
+// Ваги взято з запасом щодо cl100k_base: оцінка має радше завищувати
+// кількість токенів, ніж дозволити запиту вийти за контекстне вікно.
+const (
+	weightPerToken    = 12
+	weightASCIIWord   = 4  // латинські літери й цифри: ~3 символи на токен
+	weightASCIISpace  = 3  // пробіли й переноси: ~4 символи на токен
+	weightASCIISymbol = 6  // розділові знаки й оператори коду: ~2 символи на токен
+	weightTwoByte     = 8  // кирилиця, грецька, латиниця з діакритикою: ~1,5 символу на токен
+	weightThreeByte   = 18 // CJK та інші: ~1,5 токена на символ
+	weightFourByte    = 36 // емодзі: до 3 токенів на символ
+)
+
+// estimatePieceTokens оцінює кількість токенів фрагмента без словника BPE.
+// Це лише наближення, свідомо завищене (див. ваги вище); точний підрахунок
+// дає словник, заданий через TOKENIZER_FILE.
+func estimatePieceTokens(piece string) int {
+	weight := 0
+	for _, r := range piece {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			weight += weightASCIIWord
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			weight += weightASCIISpace
+		case r < utf8.RuneSelf:
+			weight += weightASCIISymbol
+		case utf8.RuneLen(r) == 2:
+			weight += weightTwoByte
+		case utf8.RuneLen(r) == 3:
+			weight += weightThreeByte
+		default:
+			weight += weightFourByte
+		}
+	}
+
+	return max(1, (weight+weightPerToken-1)/weightPerToken)
+}
+
+// Розміри контекстного вікна моделей у токенах
+var modelContextWindows = map[string]int{
+	"gpt-3.5-turbo":      16385,
+	"gpt-3.5-turbo-16k":  16385,
+	"gpt-4":              8192,
+	"gpt-4-32k":          32768,
+	"gpt-4-turbo":        128000,
+	"gpt-4-1106-preview": 128000,
+	"gpt-4-0125-preview": 128000,
+	"gpt-4o":             128000,
+	"gpt-4o-mini":        128000,
+	"chatgpt-4o-latest":  128000,
+	"gpt-4.1":            1047576,
+	"gpt-4.1-mini":       1047576,
+	"gpt-4.1-nano":       1047576,
+	"gpt-5":              400000,
+	"gpt-5-mini":         400000,
+	"gpt-5-nano":         400000,
+	"o1":                 200000,
+	"o1-mini":            128000,
+	"o3":                 200000,
+	"o3-mini":            200000,
+	"o4-mini":            200000,
+	"llama3":             8192,
+	"llama3.1":           131072,
+	"llama3.2":           131072,
+	"llama3.3":           131072,
+	"mistral":            32768,
+	"mixtral":            32768,
+	"qwen2.5":            32768,
+	"gemma2":             8192,
+	"phi3":               4096,
+}
+
+// Вікна сімейств моделей, якщо точної назви немає в modelContextWindows:
+// усі моделі Claude мають вікно 200 тис. токенів
+var modelFamilyWindows = map[string]int{
+	"claude-": 200000,
+}
+
+const (
+	defaultContextWindow = 4096
+	maxReplyTokens       = 1024
+)
+
+// ContextBudget повертає кількість токенів, доступну для повідомлень запиту,
+// залишаючи місце для відповіді моделі.
+func ContextBudget(model string) int {
+	window := contextWindow(model)
+
+	reserve := maxReplyTokens
+	if window/4 < reserve {
+		reserve = window / 4
+	}
+	return window - reserve
+}
+
+// contextWindow шукає вікно моделі за найдовшою назвою з точністю до версії
+// (gpt-4-0613, llama3.1:8b), потім за сімейством.
+func contextWindow(model string) int {
+	window, matched := 0, 0
+	for name, size := range modelContextWindows {
+		if matchesModel(model, name) && len(name) > matched {
+			window, matched = size, len(name)
+		}
+	}
+	if matched > 0 {
+		return window
+	}
+
+	for prefix, size := range modelFamilyWindows {
+		if strings.HasPrefix(model, prefix) {
+			return size
+		}
+	}
+	return defaultContextWindow
+}
+
+// fitToBudget залишає системні повідомлення на початку і стільки останніх
+// повідомлень, скільки вміщується в budget, починаючи з повідомлення
+// користувача. Останнє повідомлення зберігається завжди. Повертає відібрані
+// повідомлення і кількість відкинутих токенів.
+func fitToBudget(messages []Message, budget int) ([]Message, int) {
+	var system []Message
+	rest := messages
+	for len(rest) > 0 && rest[0].Role == "system" {
+		system = append(system, rest[0])
+		rest = rest[1:]
+	}
+
+	used := CountMessageTokens(system)
+	start := len(rest)
+	for start > 0 {
+		cost := CountMessageTokens(rest[start-1:start]) - tokensPerReply
+		if used+cost > budget && start < len(rest) {
+			break
+		}
+		used += cost
+		start--
+	}
+
+	// Обрізаємо цілими парами: історія має починатися з запиту користувача,
+	// бо Anthropic відхиляє розмову, що починається з відповіді асистента
+	for start < len(rest)-1 && rest[start].Role != "user" {
+		start++
+	}
+
+	dropped := 0
+	for _, m := range rest[:start] {
+		dropped += tokensPerMessage + CountTokens(m.Role) + CountTokens(m.Content)
+	}
+
+	kept := make([]Message, 0, len(system)+len(rest)-start)
+	kept = append(kept, system...)
+	kept = append(kept, rest[start:]...)
+	return kept, dropped
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Приблизна вартість зображення в токенах (1024x1024, detail: high)
+const imageTokens = 765
+
+// Image - зображення, яке надсилається моделі разом з повідомленням
+type Image struct {
+	MediaType string // image/jpeg, image/png тощо
+	Data      []byte
+}
+
+// DataURL повертає зображення у форматі data: URL для OpenAI API.
+func (i Image) DataURL() string {
+	return "data:" + i.MediaType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// contentPart - частина вмісту повідомлення у форматі OpenAI
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON надсилає текстові повідомлення рядком, а повідомлення із
+// зображеннями - масивом частин вмісту.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type textMessage struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	if len(m.Images) == 0 {
+		return json.Marshal(textMessage{Role: m.Role, Content: m.Content})
+	}
+
+	parts := make([]contentPart, 0, len(m.Images)+1)
+	if m.Content != "" {
+		parts = append(parts, contentPart{Type: "text", Text: m.Content})
+	}
+	for _, image := range m.Images {
+		parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: image.DataURL()}})
+	}
+
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []contentPart `json:"content"`
+	}{Role: m.Role, Content: parts})
+}
+
+// Моделі, що приймають зображення; перевіряються за префіксом
+var visionModelPrefixes = []string{
+	"gpt-4o", "gpt-4-turbo", "gpt-4.1", "gpt-4-vision", "o1-2", "o3", "o4",
+	"claude-3", "claude-sonnet-4", "claude-opus-4",
+	"llava", "bakllava", "llama3.2-vision", "minicpm-v", "qwen2-vl", "qwen2.5vl",
+}
+
+// IsVisionModel перевіряє, чи вміє модель працювати із зображеннями.
+func IsVisionModel(model string) bool {
+	if model == "o1" {
+		return true
+	}
+	for _, prefix := range visionModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// VisionModel повертає модель для запиту із зображенням: поточну, якщо вона
+// підтримує зображення, інакше - модель провайдера за замовчуванням.
+func VisionModel(provider, current string) string {
+	if IsVisionModel(current) {
+		return current
+	}
+
+	switch provider {
+	case ProviderAnthropic:
+		return DefaultModel(ProviderAnthropic)
+	case ProviderOllama:
+		return "llava"
+	case ProviderCompatible:
+		// Про моделі довільного сервера нічого не відомо
+		return current
+	default:
+		return DefaultOpenAIModel
+	}
+}
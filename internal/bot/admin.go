@@ -0,0 +1,301 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Пауза між повідомленнями розсилки, щоб не перевищити ліміт Telegram (~30 повідомлень/с)
+const broadcastDelay = 50 * time.Millisecond
+
+// commandHandler - обробник команди з аргументами
+type commandHandler func(message *tgbotapi.Message, args []string)
+
+// isAdmin перевіряє, чи є користувач адміністратором бота (ADMIN_IDS).
+func (b *Bot) isAdmin(userID int64) bool {
+	return b.admins[userID]
+}
+
+// adminOnly пропускає команду лише для адміністраторів.
+func (b *Bot) adminOnly(next commandHandler) commandHandler {
+	return func(message *tgbotapi.Message, args []string) {
+		if message.From == nil || !b.isAdmin(message.From.ID) {
+			logAction("ПОМИЛКА", message.Chat.ID, fmt.Sprintf("Спроба виконати адмін-команду: %s", message.Text))
+			b.sendMessage(message.Chat.ID, "🚫 Команда доступна лише адміністраторам")
+			return
+		}
+		next(message, args)
+	}
+}
+
+// checkBanned повертає true, якщо користувача заблоковано. Адміністраторів
+// блокування не стосується.
+func (b *Bot) checkBanned(chatID int64, userID int64) bool {
+	if b.isAdmin(userID) {
+		return false
+	}
+
+	banned, err := b.Storage.IsBanned(chatID)
+	if err != nil {
+		log.Printf("Помилка перевірки блокування: %v", err)
+		return false
+	}
+	return banned
+}
+
+func (b *Bot) handleAdmin(message *tgbotapi.Message, args []string) {
+	chatID := message.Chat.ID
+	if len(args) == 0 {
+		b.sendMessage(chatID, `🛠 Команди адміністратора:
+
+/admin stats - Статистика бота
+/admin user <id> - Інформація про користувача
+/admin ban <id> - Заблокувати користувача
+/admin unban <id> - Розблокувати користувача
+/admin quota <id> <n|unlimited|tier> - Персональний ліміт запитів (0 - заборонити запити)
+/admin tier <id> <рівень> - Призначити рівень доступу
+/admin purge <id> - Видалити історію, розмови та файли користувача
+/admin broadcast <текст> - Розсилка всім користувачам
+/gencode <запитів> [використань] [днів] - Створити код запрошення`)
+		return
+	}
+
+	switch args[0] {
+	case "stats":
+		b.handleAdminStats(chatID)
+	case "user":
+		if userID, ok := b.parseUserID(chatID, args, 2); ok {
+			b.handleAdminUser(chatID, userID)
+		}
+	case "ban":
+		if userID, ok := b.parseUserID(chatID, args, 2); ok {
+			b.handleAdminBan(chatID, message.From.ID, userID)
+		}
+	case "unban":
+		if userID, ok := b.parseUserID(chatID, args, 2); ok {
+			b.handleAdminUnban(chatID, userID)
+		}
+	case "quota":
+		if userID, ok := b.parseUserID(chatID, args, 3); ok {
+			b.handleAdminQuota(chatID, userID, args[2])
+		}
+	case "tier":
+		if userID, ok := b.parseUserID(chatID, args, 3); ok {
+			b.handleAdminTier(chatID, userID, args[2])
+		}
+	case "purge":
+		if userID, ok := b.parseUserID(chatID, args, 2); ok {
+			b.handleAdminPurge(chatID, userID)
+		}
+	case "broadcast":
+		_, text, _ := strings.Cut(message.Text, "broadcast")
+		b.handleAdminBroadcast(chatID, strings.TrimSpace(text))
+	default:
+		b.sendMessage(chatID, "⚠️ Невідома команда. Надішліть /admin для списку команд.")
+	}
+}
+
+// parseUserID перевіряє кількість аргументів і розбирає ID користувача з args[1].
+func (b *Bot) parseUserID(chatID int64, args []string, want int) (int64, bool) {
+	if len(args) != want {
+		b.sendMessage(chatID, "⚠️ Неправильна кількість аргументів. Надішліть /admin для списку команд.")
+		return 0, false
+	}
+
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, fmt.Sprintf("⚠️ Некоректний ID користувача: %s", args[1]))
+		return 0, false
+	}
+	return userID, true
+}
+
+func (b *Bot) handleAdminStats(chatID int64) {
+	stats, err := b.Storage.GetAdminStats()
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка статистики: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося отримати статистику")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf(`📈 Статистика бота:
+
+👥 Користувачів з ключем: %d
+🟢 Активних сьогодні: %d
+💬 Запитів сьогодні: %d
+🗂 Запитів усього: %d
+🚫 Заблоковано: %d`, stats.Users, stats.ActiveToday, stats.RequestsToday, stats.RequestsTotal, stats.Banned))
+}
+
+func (b *Bot) handleAdminUser(chatID int64, userID int64) {
+	info, err := b.Storage.GetUserInfo(userID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка отримання користувача %d: %v", userID, err))
+		b.sendMessage(chatID, "❌ Не вдалося отримати дані користувача")
+		return
+	}
+
+	status, err := b.Storage.GetLimitStatus(userID)
+	if err != nil {
+		log.Printf("Помилка отримання ліміту: %v", err)
+	}
+
+	key := "немає"
+	if info.HasAPIKey {
+		key = fmt.Sprintf("є (з %s)", info.RegisteredAt.Format("02.01.2006"))
+	}
+	lastRequest := "ніколи"
+	if !info.LastRequest.IsZero() {
+		lastRequest = info.LastRequest.Format("02.01.2006 15:04")
+	}
+	banned := "ні"
+	if info.Banned {
+		banned = "так"
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf(`👤 Користувач %d:
+
+🔑 API ключ: %s
+🔌 Провайдер: %s
+🤖 Модель: %s
+💬 Запитів усього: %d
+🕐 Останній запит: %s
+🚫 Заблоковано: %s
+
+%s`, userID, key, providerTitle(info.Provider), api.ModelDisplayName(info.Model),
+		info.Requests, lastRequest, banned, formatLimitStatus(status)))
+}
+
+// handleAdminPurge видаляє історію, розмови і файли користувача, наприклад
+// на його прохання. Ключ, налаштування та статистика витрат лишаються.
+func (b *Bot) handleAdminPurge(chatID int64, userID int64) {
+	if err := b.Storage.ClearHistory(userID); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося очистити історію %d: %v", userID, err))
+		b.sendMessage(chatID, "❌ Помилка видалення історії")
+		return
+	}
+
+	// Кешований екземпляр тримає контекст видаленої розмови
+	b.chatGPTs.Delete(userID)
+	logAction("АДМІН", chatID, fmt.Sprintf("Видалено історію користувача %d", userID))
+	b.sendMessage(chatID, fmt.Sprintf("🗑 Історію, розмови та файли користувача %d видалено", userID))
+}
+
+func (b *Bot) handleAdminBan(chatID int64, adminID, userID int64) {
+	if b.isAdmin(userID) {
+		b.sendMessage(chatID, "⚠️ Неможливо заблокувати адміністратора")
+		return
+	}
+
+	if err := b.Storage.BanUser(userID, adminID); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося заблокувати %d: %v", userID, err))
+		b.sendMessage(chatID, "❌ Помилка блокування")
+		return
+	}
+
+	b.chatGPTs.Delete(userID)
+	logAction("АДМІН", chatID, fmt.Sprintf("Заблоковано користувача %d", userID))
+	b.sendMessage(chatID, fmt.Sprintf("🚫 Користувача %d заблоковано", userID))
+}
+
+func (b *Bot) handleAdminUnban(chatID int64, userID int64) {
+	if err := b.Storage.UnbanUser(userID); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося розблокувати %d: %v", userID, err))
+		b.sendMessage(chatID, "❌ Помилка розблокування")
+		return
+	}
+
+	logAction("АДМІН", chatID, fmt.Sprintf("Розблоковано користувача %d", userID))
+	b.sendMessage(chatID, fmt.Sprintf("✅ Користувача %d розблоковано", userID))
+}
+
+// handleAdminQuota задає персональний ліміт запитів: число (0 забороняє
+// запити), unlimited - без обмежень, tier - повернути ліміт рівня.
+func (b *Bot) handleAdminQuota(chatID int64, userID int64, value string) {
+	var (
+		err     error
+		confirm string
+	)
+	switch value = strings.ToLower(value); value {
+	case "unlimited":
+		err = b.Storage.SetUnlimited(userID)
+		confirm = fmt.Sprintf("✅ Користувачу %d знято обмеження запитів", userID)
+	case "tier":
+		err = b.Storage.ResetCustomLimit(userID)
+		confirm = fmt.Sprintf("✅ Користувачу %d повернуто ліміт його рівня", userID)
+	default:
+		limit, parseErr := strconv.Atoi(value)
+		if parseErr != nil || limit < 0 {
+			b.sendMessage(chatID, "⚠️ Ліміт має бути числом ≥ 0, unlimited - без обмежень, або tier - ліміт рівня")
+			return
+		}
+		err = b.Storage.SetCustomLimit(userID, limit)
+		confirm = fmt.Sprintf("✅ Ліміт користувача %d: %d запитів", userID, limit)
+		if limit == 0 {
+			confirm = fmt.Sprintf("✅ Користувачу %d заборонено запити", userID)
+		}
+	}
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося змінити ліміт %d: %v", userID, err))
+		b.sendMessage(chatID, "❌ Помилка зміни ліміту")
+		return
+	}
+
+	logAction("АДМІН", chatID, fmt.Sprintf("Ліміт користувача %d: %s", userID, value))
+	b.sendMessage(chatID, confirm)
+}
+
+func (b *Bot) handleAdminTier(chatID int64, userID int64, tier string) {
+	if err := b.Storage.SetUserTier(userID, tier); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося змінити рівень %d: %v", userID, err))
+
+		tiers, _ := b.Storage.GetTiers()
+		names := make([]string, 0, len(tiers))
+		for _, t := range tiers {
+			names = append(names, t.Name)
+		}
+		b.sendMessage(chatID, fmt.Sprintf("❌ Помилка: %v\nДоступні рівні: %s", err, strings.Join(names, ", ")))
+		return
+	}
+
+	logAction("АДМІН", chatID, fmt.Sprintf("Рівень користувача %d: %s", userID, tier))
+	b.sendMessage(chatID, fmt.Sprintf("✅ Користувачу %d призначено рівень %s", userID, tier))
+}
+
+func (b *Bot) handleAdminBroadcast(chatID int64, text string) {
+	if text == "" {
+		b.sendMessage(chatID, "Використання: /admin broadcast <текст>")
+		return
+	}
+
+	recipients, err := b.Storage.GetBroadcastRecipients()
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка отримання отримувачів: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося отримати список користувачів")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("📣 Починаю розсилку для %d користувачів...", len(recipients)))
+
+	// Розсилка може тривати довго - не займаємо воркера
+	go func() {
+		sent := 0
+		for _, recipient := range recipients {
+			if _, err := b.api.Send(tgbotapi.NewMessage(recipient, "📣 "+text)); err != nil {
+				log.Printf("Помилка розсилки для %d: %v", recipient, err)
+			} else {
+				sent++
+			}
+			time.Sleep(broadcastDelay)
+		}
+
+		logAction("АДМІН", chatID, fmt.Sprintf("Розсилку завершено: %d/%d", sent, len(recipients)))
+		b.sendMessage(chatID, fmt.Sprintf("✅ Розсилку завершено: доставлено %d з %d", sent, len(recipients)))
+	}()
+}
@@ -1,6 +1,8 @@
 package bot
 
 import (
+	"GPTGRAMM/internal/api"
+	"GPTGRAMM/internal/config"
 	"GPTGRAMM/internal/storage"
 	"context"
 	"fmt"
@@ -13,28 +15,50 @@ import (
 type Bot struct {
 	api        *tgbotapi.BotAPI
 	Storage    *storage.Storage
+	models     *api.ModelCatalog
 	chatGPTs   sync.Map
 	users      sync.Map
 	modelCache map[int64]string 
 	messageIDs sync.Map
+	admins     map[int64]bool
+	// Хости OpenAI-сумісних серверів, які можуть обирати не лише адміністратори
+	providerHosts map[string]bool
 }
 
-func NewBot(token string) (*Bot, error) {
-	myBot, err := tgbotapi.NewBotAPI(token)
+func NewBot(cfg *config.Config) (*Bot, error) {
+	myBot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
 		return nil, fmt.Errorf("помилка створення бота: %w", err)
 	}
 
-	storage, err := storage.NewStorage()
+	keys, err := storage.NewKeyRing(cfg.EncryptionKeys)
+	if err != nil {
+		return nil, fmt.Errorf("помилка ключів шифрування: %w", err)
+	}
+
+	storage, err := storage.NewStorage(keys)
 	if err != nil {
 		return nil, fmt.Errorf("помилка ініціалізації сховища: %w", err)
 	}
 
+	admins := make(map[int64]bool, len(cfg.AdminIDs))
+	for _, id := range cfg.AdminIDs {
+		admins[id] = true
+	}
+
+	providerHosts := make(map[string]bool, len(cfg.ProviderHosts))
+	for _, host := range cfg.ProviderHosts {
+		providerHosts[host] = true
+	}
+
 	return &Bot{
-		api:        myBot,
-		Storage:    storage,
-		chatGPTs:   sync.Map{},
-		messageIDs: sync.Map{},
+		api:           myBot,
+		admins:        admins,
+		providerHosts: providerHosts,
+		Storage:       storage,
+		models:        api.NewModelCatalog(modelCatalogTTL),
+		chatGPTs:      sync.Map{},
+		messageIDs:    sync.Map{},
 	}, nil
 }
 
@@ -72,20 +96,46 @@ func (b *Bot) Start(ctx context.Context) {
 	}
 }
 
+// sendMessage надсилає текст, розбиваючи його на кілька повідомлень, якщо
+// він довший за ліміт Telegram. markdown вмикає перетворення розмітки.
 func (b *Bot) sendMessage(chatID int64, text string, markdown ...bool) {
-	msg := tgbotapi.NewMessage(chatID, text)
 	if len(markdown) > 0 && markdown[0] {
-		msg.ParseMode = tgbotapi.ModeMarkdown
+		for _, chunk := range renderMarkdown(text, telegramTextLimit) {
+			b.sendChunk(chatID, chunk)
+		}
+		return
 	}
 
-	if msg.ReplyMarkup == nil {
+	for _, part := range splitText(text, telegramTextLimit) {
+		b.sendChunk(chatID, renderedChunk{Plain: part})
+	}
+}
+
+// sendChunk надсилає частину з HTML-розміткою, а якщо Telegram її
+// відхиляє - звичайним текстом.
+func (b *Bot) sendChunk(chatID int64, chunk renderedChunk) {
+	var (
+		sent tgbotapi.Message
+		err  error
+	)
+	if chunk.HTML != "" {
+		msg := tgbotapi.NewMessage(chatID, chunk.HTML)
+		msg.ParseMode = tgbotapi.ModeHTML
 		msg.ReplyMarkup = createMainKeyboard()
+		sent, err = b.api.Send(msg)
+		if err != nil {
+			log.Printf("Помилка надсилання HTML, надсилаємо текстом: %v", err)
+		}
 	}
 
-	sent, err := b.api.Send(msg)
-	if err != nil {
-		log.Printf("Помилка надсилання повідомлення: %v", err)
-		return
+	if chunk.HTML == "" || err != nil {
+		msg := tgbotapi.NewMessage(chatID, truncateText(chunk.Plain, telegramTextLimit))
+		msg.ReplyMarkup = createMainKeyboard()
+		sent, err = b.api.Send(msg)
+		if err != nil {
+			log.Printf("Помилка надсилання повідомлення: %v", err)
+			return
+		}
 	}
 
 	queue := b.getMessageQueue(chatID)
@@ -0,0 +1,231 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"GPTGRAMM/internal/storage"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	stateAwaitingChatTitle = "awaiting_chat_title"
+
+	// Скільки останніх розмов показувати у списку
+	maxListedChats = 20
+	maxChatTitle   = 64
+	// Довжина назви на кнопці списку
+	chatButtonTitle = 30
+)
+
+// conversationName повертає назву розмови для показу користувачу
+func conversationName(c storage.Conversation) string {
+	if c.Title != "" {
+		return c.Title
+	}
+	return fmt.Sprintf("Розмова #%d", c.ID)
+}
+
+// handleChats показує список розмов. Якщо messageID не нуль, редагує
+// існуюче повідомлення замість надсилання нового.
+func (b *Bot) handleChats(chatID int64, messageID int) {
+	activeID, err := b.Storage.ActiveConversation(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання активної розмови: %v", err)
+	}
+
+	conversations, err := b.Storage.ListConversations(chatID, false, maxListedChats)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося отримати розмови: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося отримати список розмов")
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, c := range conversations {
+		title := fmt.Sprintf("%s (%d)", truncateText(conversationName(c), chatButtonTitle), c.Exchanges)
+		if c.ID == activeID {
+			title = "✅ " + title
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("chat_open_%d", c.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("✏️", fmt.Sprintf("chat_rename_%d", c.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🗄", fmt.Sprintf("chat_archive_%d", c.ID)),
+		))
+	}
+
+	controls := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("➕ Нова розмова", "chat_new")}
+	if archived, err := b.Storage.CountArchivedConversations(chatID); err != nil {
+		log.Printf("Помилка підрахунку архіву розмов: %v", err)
+	} else if archived > 0 {
+		controls = append(controls, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗄 Архів (%d)", archived), "chat_archived"))
+	}
+	rows = append(rows, controls)
+
+	text := fmt.Sprintf(`💬 Ваші розмови: %d
+
+Оберіть розмову, щоб продовжити її.
+✏️ - перейменувати, 🗄 - перенести в архів.`, len(conversations))
+	b.sendOrEditMenu(chatID, messageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// handleArchivedChats показує заархівовані розмови
+func (b *Bot) handleArchivedChats(chatID int64, messageID int) {
+	conversations, err := b.Storage.ListConversations(chatID, true, maxListedChats)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося отримати архів розмов: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося отримати архів розмов")
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, c := range conversations {
+		title := fmt.Sprintf("%s (%d)", truncateText(conversationName(c), chatButtonTitle), c.Exchanges)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("chat_restore_%d", c.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", "chat_list")))
+
+	text := "🗄 Архів розмов\n\nОберіть розмову, щоб повернути її з архіву і продовжити."
+	b.sendOrEditMenu(chatID, messageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func (b *Bot) sendOrEditMenu(chatID int64, messageID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+		if _, err := b.api.Request(edit); err != nil {
+			log.Printf("Помилка редагування повідомлення: %v", err)
+		}
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+	}
+}
+
+// handleChatCallback обробляє кнопки списку розмов: chat_<дія>[_<id>]
+func (b *Bot) handleChatCallback(chatID int64, messageID int, data string) {
+	switch data {
+	case "list":
+		b.handleChats(chatID, messageID)
+		return
+	case "archived":
+		b.handleArchivedChats(chatID, messageID)
+		return
+	case "new":
+		b.handleNewChat(chatID)
+		return
+	}
+
+	action, rawID, _ := strings.Cut(data, "_")
+	conversationID, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, "Некоректний формат callback.Data")
+		return
+	}
+
+	conversation, err := b.Storage.GetConversation(chatID, conversationID)
+	if err != nil {
+		log.Printf("Помилка отримання розмови: %v", err)
+	}
+	if conversation == nil {
+		b.sendMessage(chatID, "⚠️ Розмову не знайдено")
+		return
+	}
+
+	switch action {
+	case "open":
+		b.openConversation(chatID, *conversation)
+	case "restore":
+		if err := b.Storage.ArchiveConversation(chatID, conversationID, false); err != nil {
+			b.sendMessage(chatID, "❌ Не вдалося повернути розмову з архіву")
+			return
+		}
+		logAction("РОЗМОВА", chatID, fmt.Sprintf("Повернено з архіву #%d", conversationID))
+		b.openConversation(chatID, *conversation)
+		b.handleArchivedChats(chatID, messageID)
+	case "archive":
+		if err := b.Storage.ArchiveConversation(chatID, conversationID, true); err != nil {
+			b.sendMessage(chatID, "❌ Не вдалося заархівувати розмову")
+			return
+		}
+		logAction("РОЗМОВА", chatID, fmt.Sprintf("Заархівовано #%d", conversationID))
+
+		// Якщо заархівовано активну розмову, активною стане інша
+		b.reattachConversation(chatID)
+		b.handleChats(chatID, messageID)
+	case "rename":
+		b.users.Store(fmt.Sprintf("%d_state", chatID), stateAwaitingChatTitle)
+		b.users.Store(fmt.Sprintf("%d_chat_rename", chatID), conversationID)
+		b.sendMessage(chatID, fmt.Sprintf("✏️ Надішліть нову назву для розмови «%s» (до %d символів).",
+			conversationName(*conversation), maxChatTitle))
+	default:
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідома дія з розмовою: %s", action))
+	}
+}
+
+// openConversation робить розмову активною і показує, на чому вона зупинилася.
+func (b *Bot) openConversation(chatID int64, conversation storage.Conversation) {
+	if err := b.Storage.SetActiveConversation(chatID, conversation.ID); err != nil {
+		log.Printf("Помилка перемикання розмови: %v", err)
+		b.sendMessage(chatID, "❌ Не вдалося перемкнути розмову")
+		return
+	}
+	b.reattachConversation(chatID)
+	logAction("РОЗМОВА", chatID, fmt.Sprintf("Відкрито #%d %s", conversation.ID, conversation.Title))
+
+	text := fmt.Sprintf("▶️ Продовжуємо розмову «%s»\n💬 Обмінів повідомленнями: %d",
+		conversationName(conversation), conversation.Exchanges)
+
+	messages, err := b.Storage.GetMessages(conversation.ID, 1)
+	if err != nil {
+		log.Printf("Помилка отримання повідомлень: %v", err)
+	}
+	if len(messages) > 0 {
+		text += "\n\nОстаннє повідомлення:\n" + truncateText(messages[0].Content, 500)
+	}
+	b.sendMessage(chatID, text)
+}
+
+// reattachConversation прив'язує завантажений GPT-екземпляр до поточної
+// активної розмови. Якщо екземпляра ще немає, він прив'яжеться при створенні.
+func (b *Bot) reattachConversation(chatID int64) {
+	gptInstance, ok := b.chatGPTs.Load(chatID)
+	if !ok {
+		return
+	}
+	if err := b.attachConversation(chatID, gptInstance.(*api.ChatGPT)); err != nil {
+		log.Printf("Помилка перемикання розмови: %v", err)
+	}
+}
+
+func (b *Bot) handleChatTitle(chatID int64, text string) {
+	value, ok := b.users.LoadAndDelete(fmt.Sprintf("%d_chat_rename", chatID))
+	if !ok {
+		return
+	}
+	conversationID := value.(int64)
+
+	title := strings.Join(strings.Fields(text), " ")
+	if title == "" || utf8.RuneCountInString(title) > maxChatTitle {
+		b.sendMessage(chatID, fmt.Sprintf("⚠️ Назва має містити від 1 до %d символів. Спробуйте ще раз через /chats.", maxChatTitle))
+		return
+	}
+
+	if err := b.Storage.RenameConversation(chatID, conversationID, title); err != nil {
+		log.Printf("Помилка перейменування розмови: %v", err)
+		b.sendMessage(chatID, "❌ Не вдалося перейменувати розмову")
+		return
+	}
+
+	logAction("РОЗМОВА", chatID, fmt.Sprintf("Перейменовано #%d: %s", conversationID, title))
+	b.sendMessage(chatID, fmt.Sprintf("✅ Розмову перейменовано на «%s»", title))
+}
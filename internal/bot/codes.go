@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/storage"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultCodeUses = 1
+	defaultCodeDays = 30
+)
+
+// generateCode повертає випадковий код виду GPT-ABCD-EFGH-IJKL
+func generateCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)[:12]
+	return fmt.Sprintf("GPT-%s-%s-%s", raw[:4], raw[4:8], raw[8:12]), nil
+}
+
+// handleGenCode - команда адміністратора /gencode <запитів> [використань] [днів]
+func (b *Bot) handleGenCode(message *tgbotapi.Message, args []string) {
+	chatID := message.Chat.ID
+	userID := message.From.ID
+
+	usage := "Використання: /gencode <запитів> [використань] [днів]\nНаприклад: /gencode 50 10 7"
+	if len(args) < 1 || len(args) > 3 {
+		b.sendMessage(chatID, usage)
+		return
+	}
+
+	values := []int{0, defaultCodeUses, defaultCodeDays}
+	for i, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			b.sendMessage(chatID, "⚠️ Усі параметри мають бути додатними числами.\n"+usage)
+			return
+		}
+		values[i] = n
+	}
+	quota, uses, days := values[0], values[1], values[2]
+
+	code, err := generateCode()
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося згенерувати код: %v", err))
+		b.sendMessage(chatID, "❌ Помилка генерації коду")
+		return
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, days)
+	if err := b.Storage.CreateRedeemCode(code, quota, uses, expiresAt, userID); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося зберегти код: %v", err))
+		b.sendMessage(chatID, "❌ Помилка збереження коду")
+		return
+	}
+
+	logAction("КОД", chatID, fmt.Sprintf("Створено код: %d запитів, %d використань, до %s", quota, uses, expiresAt.Format("02.01.2006")))
+	b.sendMessage(chatID, fmt.Sprintf(`🎟 Код створено (показується лише один раз):
+
+%s
+
+➕ Запитів: %d
+👥 Використань: %d
+⏳ Діє до: %s
+
+Користувач активує його командою /redeem %s`, code, quota, uses, expiresAt.Format("02.01.2006 15:04"), code))
+}
+
+// handleRedeem - /redeem <код>
+func (b *Bot) handleRedeem(chatID int64, args []string) {
+	if len(args) != 1 {
+		b.sendMessage(chatID, "Використання: /redeem <код>")
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(args[0]))
+	quota, err := b.Storage.RedeemCode(chatID, code)
+	if err != nil {
+		logAction("КОД", chatID, fmt.Sprintf("Невдале погашення коду: %v", err))
+
+		switch {
+		case errors.Is(err, storage.ErrCodeNotFound),
+			errors.Is(err, storage.ErrCodeExpired),
+			errors.Is(err, storage.ErrCodeExhausted),
+			errors.Is(err, storage.ErrCodeAlreadyRedeemed):
+			b.sendMessage(chatID, fmt.Sprintf("⚠️ Не вдалося активувати код: %v", err))
+		default:
+			b.sendMessage(chatID, "❌ Помилка активації коду. Спробуйте пізніше.")
+		}
+		return
+	}
+
+	logAction("КОД", chatID, fmt.Sprintf("Код погашено, нараховано %d запитів", quota))
+	b.sendMessage(chatID, fmt.Sprintf("✅ Код активовано! Нараховано бонусних запитів: %d", quota))
+}
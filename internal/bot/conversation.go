@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"GPTGRAMM/internal/storage"
+)
+
+// conversationStore зберігає контекст ChatGPT у SQLite через storage.Storage
+type conversationStore struct {
+	storage *storage.Storage
+}
+
+func (s *conversationStore) LoadMessages(conversationID int64, limit int) ([]api.Message, error) {
+	stored, err := s.storage.GetMessages(conversationID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]api.Message, 0, len(stored))
+	for _, m := range stored {
+		messages = append(messages, api.Message{Role: m.Role, Content: m.Content})
+	}
+	return messages, nil
+}
+
+func (s *conversationStore) AppendMessages(conversationID int64, messages ...api.Message) error {
+	stored := make([]storage.Message, 0, len(messages))
+	for _, m := range messages {
+		stored = append(stored, storage.Message{Role: m.Role, Content: m.Content})
+	}
+	return s.storage.AppendMessages(conversationID, stored...)
+}
+
+func (s *conversationStore) ClearMessages(conversationID int64) error {
+	return s.storage.ClearMessages(conversationID)
+}
+
+// attachConversation прив'язує GPT-екземпляр до активної розмови користувача.
+func (b *Bot) attachConversation(chatID int64, gpt *api.ChatGPT) error {
+	conversationID, err := b.Storage.ActiveConversation(chatID)
+	if err != nil {
+		return err
+	}
+
+	gpt.SetStore(&conversationStore{storage: b.Storage}, conversationID)
+	return nil
+}
@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Відповіді, довші за цю кількість символів, надсилаються файлом
+	documentTextThreshold = 2 * telegramTextLimit
+	// Блок коду з такою кількістю рядків надсилається файлом
+	documentCodeLines = 60
+	// Довжина уривка відповіді в повідомленні з файлом
+	documentSummaryLen = 600
+)
+
+// Розширення файлів за мовою з ```
+var codeExtensions = map[string]string{
+	"go":         "go",
+	"golang":     "go",
+	"python":     "py",
+	"py":         "py",
+	"javascript": "js",
+	"js":         "js",
+	"typescript": "ts",
+	"ts":         "ts",
+	"tsx":        "tsx",
+	"jsx":        "jsx",
+	"java":       "java",
+	"kotlin":     "kt",
+	"swift":      "swift",
+	"c":          "c",
+	"cpp":        "cpp",
+	"c++":        "cpp",
+	"csharp":     "cs",
+	"cs":         "cs",
+	"c#":         "cs",
+	"rust":       "rs",
+	"ruby":       "rb",
+	"php":        "php",
+	"bash":       "sh",
+	"sh":         "sh",
+	"shell":      "sh",
+	"sql":        "sql",
+	"json":       "json",
+	"yaml":       "yaml",
+	"yml":        "yaml",
+	"html":       "html",
+	"css":        "css",
+	"xml":        "xml",
+	"dockerfile": "dockerfile",
+}
+
+// responseDocument - відповідь, яку краще надіслати файлом
+type responseDocument struct {
+	Name    string
+	Content string
+	Summary string // текст для повідомлення з кнопкою
+}
+
+// pendingDocument - відповідь, яку можна показати в чаті замість файлу.
+// Зберігається лише остання, щоб не тримати в пам'яті всі довгі відповіді.
+type pendingDocument struct {
+	messageID int
+	text      string
+}
+
+// detectDocument вирішує, чи надсилати відповідь файлом. Якщо у відповіді
+// один великий блок коду, файлом іде сам код з розширенням його мови,
+// інакше - вся відповідь у .md.
+func detectDocument(response string) (responseDocument, bool) {
+	var (
+		code       []mdBlock
+		prose      []string
+		bigCode    bool
+		totalLines int
+	)
+	for _, block := range parseBlocks(response) {
+		if block.fence == "" {
+			prose = append(prose, block.text)
+			continue
+		}
+		code = append(code, block)
+		lines := strings.Count(block.text, "\n") + 1
+		totalLines += lines
+		if lines >= documentCodeLines {
+			bigCode = true
+		}
+	}
+
+	if !bigCode && utf8.RuneCountInString(response) <= documentTextThreshold {
+		return responseDocument{}, false
+	}
+
+	if len(code) == 1 && bigCode {
+		lang := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(code[0].fence, "```")))
+		if ext, ok := codeExtensions[lang]; ok {
+			summary := strings.Join(prose, "\n\n")
+			if summary == "" {
+				summary = fmt.Sprintf("Код (%d рядків)", totalLines)
+			}
+			return responseDocument{
+				Name:    "code." + ext,
+				Content: code[0].text + "\n",
+				Summary: summary,
+			}, true
+		}
+	}
+
+	summary := ""
+	if len(prose) > 0 {
+		summary = prose[0]
+	}
+	return responseDocument{
+		Name:    "response.md",
+		Content: response,
+		Summary: summary,
+	}, true
+}
+
+// sendResponseDocument замінює повідомлення потоку коротким описом з кнопкою
+// перегляду в чаті і надсилає відповідь файлом.
+func (b *Bot) sendResponseDocument(chatID int64, stream *streamMessage, doc responseDocument, response string) {
+	summary := truncateText(doc.Summary, documentSummaryLen)
+	if utf8.RuneCountInString(doc.Summary) > documentSummaryLen {
+		summary += "..."
+	}
+	text := fmt.Sprintf("%s\n\n📎 Відповідь велика (%d символів), надсилаю файлом %s",
+		summary, utf8.RuneCountInString(response), doc.Name)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👁 Показати в чаті", fmt.Sprintf("view_inline_%d", stream.messageID)),
+		),
+	)
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, stream.messageID, strings.TrimSpace(text), keyboard)
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("Помилка редагування повідомлення: %v", err)
+	}
+
+	b.users.Store(fmt.Sprintf("%d_document", chatID), pendingDocument{messageID: stream.messageID, text: response})
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  doc.Name,
+		Bytes: []byte(doc.Content),
+	})
+	sent, err := b.api.Send(document)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося надіслати файл: %v", err))
+		// Без файлу показуємо відповідь у чаті, щоб вона не загубилася
+		stream.Finish(response)
+		return
+	}
+
+	queue := b.getMessageQueue(chatID)
+	queue.Add(sent.MessageID)
+	logAction("ФАЙЛ", chatID, fmt.Sprintf("Відповідь надіслано файлом %s", doc.Name))
+}
+
+// handleViewInline показує в чаті відповідь, надіслану файлом.
+func (b *Bot) handleViewInline(chatID int64, data string) {
+	messageID, err := strconv.Atoi(strings.TrimPrefix(data, "view_inline_"))
+	if err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("%d_document", chatID)
+	value, ok := b.users.Load(key)
+	pending, _ := value.(pendingDocument)
+	if !ok || pending.messageID != messageID {
+		b.sendMessage(chatID, "⚠️ Ця відповідь більше недоступна для перегляду в чаті, відкрийте файл.")
+		return
+	}
+	b.users.Delete(key)
+
+	// Прибираємо кнопку, щоб відповідь не надсилалася повторно
+	removeButton := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	if _, err := b.api.Request(removeButton); err != nil {
+		log.Printf("Помилка редагування повідомлення: %v", err)
+	}
+
+	b.sendMessage(chatID, pending.text, true)
+}
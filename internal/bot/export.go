@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/export"
+	"GPTGRAMM/internal/storage"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var exportFormatTitles = map[string]string{
+	export.FormatMarkdown: "📝 Markdown",
+	export.FormatJSON:     "🧾 JSON",
+	export.FormatHTML:     "🌐 HTML",
+}
+
+// handleExport пропонує обрати формат і обсяг експорту: поточну розмову
+// або всю історію.
+func (b *Bot) handleExport(chatID int64) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, scope := range []string{"chat", "all"} {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, format := range export.Formats {
+			title := exportFormatTitles[format]
+			if scope == "all" {
+				title += " (усе)"
+			}
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("export_%s_%s", format, scope)))
+		}
+		rows = append(rows, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, `📤 Експорт історії
+
+Перший рядок - поточна розмова, другий - уся історія.
+JSON збережено у форматі повідомлень OpenAI, HTML відкривається в будь-якому браузері.`)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+	}
+}
+
+// handleExportCallback формує файл експорту і надсилає його документом.
+// Історія читається зі сховища сторінками і пишеться прямо у завантаження.
+func (b *Bot) handleExportCallback(chatID int64, data string) {
+	format, scope, _ := strings.Cut(data, "_")
+	if !slices.Contains(export.Formats, format) || (scope != "chat" && scope != "all") {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідомі параметри експорту: %s", data))
+		return
+	}
+
+	var (
+		conversationID int64
+		title          = "Історія чату"
+	)
+	if scope == "chat" {
+		id, err := b.Storage.ActiveConversation(chatID)
+		if err != nil {
+			log.Printf("Помилка отримання активної розмови: %v", err)
+			b.sendMessage(chatID, "❌ Не вдалося отримати розмову")
+			return
+		}
+		conversationID = id
+
+		if conversation, err := b.Storage.GetConversation(chatID, id); err == nil && conversation != nil {
+			title = conversationName(*conversation)
+		}
+	}
+
+	count, err := b.Storage.CountHistory(chatID, conversationID)
+	if err != nil {
+		log.Printf("Помилка підрахунку історії: %v", err)
+		b.sendMessage(chatID, "❌ Не вдалося прочитати історію")
+		return
+	}
+	if count == 0 {
+		b.sendMessage(chatID, "ℹ️ Історія порожня, експортувати нічого")
+		return
+	}
+
+	action := tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument)
+	if _, err := b.api.Request(action); err != nil {
+		log.Printf("Помилка надсилання дії: %v", err)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(b.writeExport(writer, chatID, conversationID, format, title))
+	}()
+
+	name := fmt.Sprintf("chat_%s.%s", time.Now().Format("2006-01-02"), format)
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{Name: name, Reader: reader})
+	doc.Caption = fmt.Sprintf("📤 %s: %d обмінів повідомленнями", title, count)
+
+	_, err = b.api.Send(doc)
+	// Якщо надсилання перервалося, звільняємо горутину запису
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка експорту: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося надіслати файл експорту")
+		return
+	}
+
+	logAction("ЕКСПОРТ", chatID, fmt.Sprintf("%s, записів: %d, розмова: %d", format, count, conversationID))
+}
+
+func (b *Bot) writeExport(w io.Writer, chatID, conversationID int64, format, title string) error {
+	out, err := export.NewWriter(format, w, title)
+	if err != nil {
+		return err
+	}
+
+	err = b.Storage.EachHistoryEntry(chatID, conversationID, func(entry storage.HistoryEntry) error {
+		return out.WriteExchange(export.Exchange{
+			Prompt:    entry.Message,
+			Response:  entry.Response,
+			CreatedAt: entry.CreatedAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
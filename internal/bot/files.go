@@ -0,0 +1,284 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"GPTGRAMM/internal/document"
+	"GPTGRAMM/internal/importer"
+	"GPTGRAMM/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Файли до цієї довжини вставляються в розмову повністю
+	inlineFileLimit = 12000
+	// Розмір фрагмента великого файлу в символах
+	fileChunkSize = 3000
+	// Більші файли не індексуємо, щоб не витрачати забагато на ембединги
+	maxFileChunks = 500
+	// Скільки фрагментів за раз надсилати на ембединги
+	embeddingBatchSize = 64
+	// Скільки найближчих фрагментів додавати до запитання
+	retrievedChunks = 4
+
+	// Запитання до файлу без підпису
+	defaultFileQuestion = "Коротко перекажи зміст цього файлу"
+)
+
+// handleDocument приймає текстовий файл чи PDF. Невеликий файл одразу
+// потрапляє в розмову, а великий розбивається на фрагменти, з яких до
+// наступних запитань додаються найближчі за змістом.
+func (b *Bot) handleDocument(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	file := message.Document
+
+	name := file.FileName
+	if name == "" {
+		name = "file.txt"
+	}
+	logAction("ФАЙЛ", chatID, fmt.Sprintf("Отримано файл %s (%d байт)", name, file.FileSize))
+
+	if file.FileSize > maxDownloadSize {
+		b.sendMessage(chatID, "⚠️ Файл завеликий, Telegram дозволяє ботам завантажувати до 20 МБ.")
+		return
+	}
+
+	gpt, err := b.getOrCreateGPTInstance(chatID)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	resp, err := b.downloadFile(ctx, file.FileID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка завантаження файлу: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося завантажити файл")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize))
+	resp.Body.Close()
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка читання файлу: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося завантажити файл")
+		return
+	}
+
+	// Експорт даних ChatGPT імпортується як розмова, а не як документ
+	if importer.IsChatGPTExport(name, data) {
+		b.handleChatGPTExport(chatID, name, data)
+		return
+	}
+
+	text, err := document.Extract(name, data)
+	switch {
+	case errors.Is(err, document.ErrUnsupported):
+		b.sendMessage(chatID, "⚠️ Цей формат не підтримується. Надішліть PDF або текстовий файл (txt, md, код, csv, json тощо).")
+		return
+	case errors.Is(err, document.ErrTooLarge):
+		b.sendMessage(chatID, "⚠️ Файл завеликий для обробки: після розпакування він займає понад 64 МБ.")
+		return
+	case errors.Is(err, document.ErrNoText):
+		b.sendMessage(chatID, "⚠️ У файлі не знайдено тексту. Скановані PDF без текстового шару не підтримуються.")
+		return
+	case err != nil:
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка розбору файлу: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося прочитати файл")
+		return
+	}
+
+	question := strings.TrimSpace(message.Caption)
+	if utf8.RuneCountInString(text) <= inlineFileLimit {
+		if question == "" {
+			question = defaultFileQuestion
+		}
+		b.handleGPTRequest(chatID, fmt.Sprintf("Файл %s:\n```\n%s\n```\n\n%s", name, text, question))
+		return
+	}
+
+	chunks := document.Chunk(text, fileChunkSize)
+	if len(chunks) > maxFileChunks {
+		b.sendMessage(chatID, fmt.Sprintf("⚠️ Файл задовгий: максимум %d фрагментів по %d символів.", maxFileChunks, fileChunkSize))
+		return
+	}
+
+	// Ліміт перевіряємо лише для файлу, який вдалося прочитати
+	if !b.requestAllowed(chatID) {
+		return
+	}
+
+	saved, err := b.indexDocument(ctx, chatID, gpt, name, len(data), chunks)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка збереження файлу: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося зберегти файл")
+		return
+	}
+	if !saved {
+		return
+	}
+
+	// Запитання з підпису списується з ліміту в handleGPTRequest, а без нього
+	// запитом вважається сама індексація
+	if question == "" {
+		b.checkRequestLimit(chatID)
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf(`📄 Файл %s збережено: %d фрагментів.
+
+Ставте запитання - відповіді спиратимуться на вміст файлу до початку 🔄 Нового чату.`, name, len(chunks)))
+
+	if question != "" {
+		b.handleGPTRequest(chatID, question)
+	}
+}
+
+// indexDocument рахує ембединги фрагментів і зберігає документ у поточній
+// розмові. Якщо провайдер не вміє рахувати ембединги, фрагменти зберігаються
+// без них і шукаються за словами. Повертає false, якщо запит відхилено
+// лімітом витрат.
+func (b *Bot) indexDocument(ctx context.Context, chatID int64, gpt *api.ChatGPT, name string, size int, chunks []string) (bool, error) {
+	provider, _, _ := b.Storage.GetProvider(chatID)
+	model := api.EmbeddingModel(provider)
+
+	stored := make([]storage.DocumentChunk, len(chunks))
+	for i, chunk := range chunks {
+		stored[i] = storage.DocumentChunk{Index: i, Content: chunk}
+	}
+
+	if model != "" {
+		tokens := 0
+		for _, chunk := range chunks {
+			tokens += api.CountTokens(chunk)
+		}
+		if !b.checkModelPriced(chatID, model) {
+			return false, nil
+		}
+		spent, spendingCap, allowed := b.checkSpendingCap(chatID, api.EstimateCost(model, api.Usage{PromptTokens: tokens}))
+		if !allowed {
+			return false, nil
+		}
+
+		embeddingModel := model
+		vectors, usage, err := embedAll(ctx, gpt, model, chunks)
+		if err != nil {
+			logAction("ПОМИЛКА", chatID, fmt.Sprintf("Ембединги недоступні, шукатимемо за словами: %v", err))
+			model = ""
+		} else {
+			for i := range stored {
+				stored[i].Embedding = vectors[i]
+			}
+		}
+
+		if usage.Total() > 0 {
+			cost := api.EstimateCost(embeddingModel, usage)
+			if err := b.Storage.RecordUsage(chatID, embeddingModel, usage.PromptTokens, 0, cost); err != nil {
+				log.Printf("Помилка збереження використання: %v", err)
+			}
+			b.warnSpending(chatID, spent, spent+cost, spendingCap)
+		}
+	}
+
+	doc := storage.Document{Name: name, Size: size, EmbeddingModel: model}
+	if _, err := b.Storage.SaveDocument(chatID, gpt.ConversationID(), doc, stored); err != nil {
+		return false, err
+	}
+
+	logAction("ФАЙЛ", chatID, fmt.Sprintf("Збережено %s: фрагментів=%d, ембединги=%s", name, len(chunks), model))
+	return true, nil
+}
+
+// embedAll рахує ембединги пакетами і підсумовує використані токени.
+func embedAll(ctx context.Context, gpt *api.ChatGPT, model string, inputs []string) ([][]float32, api.Usage, error) {
+	var (
+		vectors [][]float32
+		total   api.Usage
+	)
+	for start := 0; start < len(inputs); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(inputs))
+		batch, usage, err := gpt.Embed(ctx, model, inputs[start:end])
+		total.PromptTokens += usage.PromptTokens
+		if err != nil {
+			return nil, total, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, total, nil
+}
+
+// documentGrounding повертає найближчі до запитання фрагменти документа
+// поточної розмови або "", якщо документа немає.
+func (b *Bot) documentGrounding(chatID int64, gpt *api.ChatGPT, question string) string {
+	doc, err := b.Storage.ActiveDocument(gpt.ConversationID())
+	if err != nil {
+		log.Printf("Помилка отримання документа: %v", err)
+		return ""
+	}
+	if doc == nil {
+		return ""
+	}
+
+	chunks, err := b.Storage.GetDocumentChunks(doc.ID)
+	if err != nil {
+		log.Printf("Помилка отримання фрагментів документа: %v", err)
+		return ""
+	}
+	if len(chunks) == 0 {
+		return ""
+	}
+
+	scores := b.scoreChunks(chatID, gpt, doc.EmbeddingModel, question, chunks)
+
+	// Обрані фрагменти подаємо в порядку документа, щоб зберегти зв'язність
+	indexes := document.TopK(scores, retrievedChunks)
+	slices.Sort(indexes)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Фрагменти файлу %s, на які слід спиратися у відповіді. Якщо відповіді в них немає, так і скажи.", doc.Name)
+	for _, i := range indexes {
+		fmt.Fprintf(&sb, "\n\n[фрагмент %d з %d]\n%s", chunks[i].Index+1, len(chunks), chunks[i].Content)
+	}
+
+	logAction("ФАЙЛ", chatID, fmt.Sprintf("Додано фрагментів %s: %d", doc.Name, len(indexes)))
+	return sb.String()
+}
+
+// scoreChunks оцінює близькість фрагментів до запитання за ембедингами,
+// а якщо вони недоступні - за збігом слів.
+func (b *Bot) scoreChunks(chatID int64, gpt *api.ChatGPT, model, question string, chunks []storage.DocumentChunk) []float64 {
+	scores := make([]float64, len(chunks))
+
+	if model != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		vectors, usage, err := gpt.Embed(ctx, model, []string{question})
+		if err == nil {
+			cost := api.EstimateCost(model, usage)
+			if err := b.Storage.RecordUsage(chatID, model, usage.PromptTokens, 0, cost); err != nil {
+				log.Printf("Помилка збереження використання: %v", err)
+			}
+
+			for i, chunk := range chunks {
+				scores[i] = document.Cosine(vectors[0], chunk.Embedding)
+			}
+			return scores
+		}
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Ембединг запитання недоступний, шукаємо за словами: %v", err))
+	}
+
+	for i, chunk := range chunks {
+		scores[i] = document.LexicalScore(question, chunk.Content)
+	}
+	return scores
+}
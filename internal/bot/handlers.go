@@ -2,6 +2,8 @@ package bot
 
 import (
 	"GPTGRAMM/internal/api"
+	"GPTGRAMM/internal/storage"
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -17,12 +19,57 @@ func (b *Bot) handleMessage(message *tgbotapi.Message) {
 	queue := b.getMessageQueue(chatID)
 	queue.Add(message.MessageID)
 
+	if message.From != nil && b.checkBanned(chatID, message.From.ID) {
+		logAction("ЗАБЛОКОВАНО", chatID, "Повідомлення від заблокованого користувача")
+		b.sendMessage(chatID, "🚫 Ваш доступ до бота заблоковано адміністратором")
+		return
+	}
+
+	if message.Voice != nil || message.Audio != nil {
+		b.handleVoice(message)
+		return
+	}
+
+	if len(message.Photo) > 0 {
+		b.handlePhoto(message)
+		return
+	}
+
+	if message.Document != nil {
+		b.handleDocument(message)
+		return
+	}
+
 	if state, ok := b.users.Load(fmt.Sprintf("%d_state", chatID)); ok && state == "awaiting_city" {
 		b.getWeatherForCity(chatID, text)
 		b.users.Delete(fmt.Sprintf("%d_state", chatID))
 		return
 	}
 
+	if state, ok := b.users.Load(fmt.Sprintf("%d_state", chatID)); ok && state == stateAwaitingPrompt {
+		b.users.Delete(fmt.Sprintf("%d_state", chatID))
+		b.handleCustomPrompt(chatID, text)
+		return
+	}
+
+	if state, ok := b.users.Load(fmt.Sprintf("%d_state", chatID)); ok && state == stateAwaitingBaseURL {
+		b.users.Delete(fmt.Sprintf("%d_state", chatID))
+		b.handleBaseURL(chatID, text)
+		return
+	}
+
+	if state, ok := b.users.Load(fmt.Sprintf("%d_state", chatID)); ok && state == stateAwaitingSpendingCap {
+		b.users.Delete(fmt.Sprintf("%d_state", chatID))
+		b.handleSpendingCap(chatID, text)
+		return
+	}
+
+	if state, ok := b.users.Load(fmt.Sprintf("%d_state", chatID)); ok && state == stateAwaitingChatTitle {
+		b.users.Delete(fmt.Sprintf("%d_state", chatID))
+		b.handleChatTitle(chatID, text)
+		return
+	}
+
 	switch text {
 	case "/start":
 		logAction("КОМАНДА", chatID, "👋 Початок роботи")
@@ -35,26 +82,46 @@ func (b *Bot) handleMessage(message *tgbotapi.Message) {
 		b.handleSettings(chatID)
 	case "🔄 Новий чат":
 		b.handleNewChat(chatID)
+	case "/forgetkey":
+		logAction("КОМАНДА", chatID, "🗑 Видалення API ключа")
+		b.handleForgetKey(chatID)
 	case "❓ Допомога":
 		logAction("КОМАНДА", chatID, "❓ Запит допомоги")
 		b.handleHelp(chatID)
 	case "🌞 Погода":
 		logAction("КОМАНДА", chatID, "🌞 Запит кастомної погоди")
 		b.customWeather(chatID)
-	case bypassCode:
-		logAction("КОМАНДА", chatID, "🔓 Використано код обходу ліміту")
-		b.handleBypassCode(chatID)
 	default:
-		if len(text) > 3 && text[:3] == "sk-" {
+		command, args := parseCommand(text)
+		switch {
+		case command == "/chats":
+			logAction("КОМАНДА", chatID, "💬 Список розмов")
+			b.handleChats(chatID, 0)
+		case command == "/clear":
+			logAction("КОМАНДА", chatID, "🧹 Очищення контексту")
+			b.handleClearContext(chatID)
+		case command == "/search":
+			logAction("КОМАНДА", chatID, "🔍 Пошук в історії")
+			b.handleSearch(chatID, strings.Join(args, " "))
+		case command == "/export":
+			logAction("КОМАНДА", chatID, "📤 Експорт історії")
+			b.handleExport(chatID)
+		case command == "/image":
+			logAction("КОМАНДА", chatID, "🎨 Генерація зображення")
+			b.handleImageCommand(chatID, strings.TrimSpace(strings.TrimPrefix(text, strings.Fields(text)[0])))
+		case command == "/redeem":
+			logAction("КОМАНДА", chatID, "🎟 Активація коду")
+			b.handleRedeem(chatID, args)
+		case command == "/gencode":
+			logAction("КОМАНДА", chatID, "🎟 Створення коду")
+			b.adminOnly(b.handleGenCode)(message, args)
+		case command == "/admin":
+			logAction("КОМАНДА", chatID, "🛠 Команда адміністратора")
+			b.adminOnly(b.handleAdmin)(message, args)
+		case len(text) > 3 && text[:3] == "sk-":
 			logAction("КОМАНДА", chatID, "🔑 Отримано API ключ")
-			b.handleAPIKey(chatID, text)
-		} else {
-			if !b.checkRequestLimit(chatID) && text != bypassCode {
-				logAction("ПОМИЛКА", chatID, "⚠️ Досягнуто ліміт запитів")
-				b.sendMessage(chatID, "⚠️ Ви досягли ліміту запитів на сьогодні. Використайте кодову фразу для необмеженого доступу.")
-				return
-			}
-
+			b.handleAPIKey(chatID, message.MessageID, text)
+		default:
 			b.handleGPTRequest(chatID, text)
 		}
 	}
@@ -79,27 +146,90 @@ func (b *Bot) handleStart(chatID int64) {
 }
 
 func (b *Bot) handleStats(chatID int64) {
-	value, exists := b.users.Load(chatID)
-	var requestCount int
-	if exists && value != nil {
-		settings := value.(UserSettings)
-		requestCount = settings.RequestCount
+	status, err := b.Storage.GetLimitStatus(chatID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка отримання ліміту: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося отримати статистику")
+		return
+	}
+
+	text := "📊 Статистика:\n" + formatLimitStatus(status)
+	if status.Bonus > 0 {
+		text += fmt.Sprintf("\nБонусних запитів: %d", status.Bonus)
+	}
+
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	today, err := b.Storage.GetUsageSummary(chatID, startOfDay)
+	if err != nil {
+		log.Printf("Помилка отримання використання: %v", err)
+	}
+	month, err := b.Storage.GetUsageSummary(chatID, startOfMonth(now))
+	if err != nil {
+		log.Printf("Помилка отримання використання: %v", err)
 	}
 
-	text := fmt.Sprintf("📊 Статистика:\nЗапитів сьогодні: %d/%d",
-		requestCount, maxRequestsPerDay)
+	text += "\n\n" + formatUsage("Сьогодні", today) + "\n\n" + formatUsage("Цього місяця", month)
 	b.sendMessage(chatID, text)
 }
 
+func formatUsage(period string, usage storage.UsageSummary) string {
+	return fmt.Sprintf("%s:\nЗапитів: %d\nТокенів: %d (запит %d, відповідь %d)\nОрієнтовні витрати: $%.4f",
+		period, usage.Requests, usage.TotalTokens(), usage.PromptTokens, usage.CompletionTokens, usage.Cost)
+}
+
+func formatLimitStatus(status storage.LimitStatus) string {
+	if status.Blocked {
+		return fmt.Sprintf("Рівень: %s\nЗапитів: заборонено адміністратором", status.Tier.Name)
+	}
+	if status.Tier.Limit == 0 {
+		return fmt.Sprintf("Рівень: %s\nЗапитів: без обмежень", status.Tier.Name)
+	}
+
+	switch status.Tier.Policy {
+	case storage.PolicyTokenBucket:
+		return fmt.Sprintf("Рівень: %s\nДоступно запитів: %d/%d\nНаступний запит відновиться о %s",
+			status.Tier.Name, status.Remaining, status.Tier.Limit, status.ResetAt.Format("15:04"))
+	default:
+		return fmt.Sprintf("Рівень: %s\nЗапитів сьогодні: %d/%d\nЛіміт оновиться %s",
+			status.Tier.Name, status.Used, status.Tier.Limit, status.ResetAt.Format("02.01 о 15:04"))
+	}
+}
+
 func (b *Bot) handleSettings(chatID int64) {
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+	rows := [][]tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("GPT-3.5", "model_gpt3"),
-			tgbotapi.NewInlineKeyboardButtonData("GPT-4", "model_gpt4"),
+			tgbotapi.NewInlineKeyboardButtonData("🤖 Модель", "models_page_0"),
 		),
-	)
+	}
+	rows = append(rows, personaKeyboard()...)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔌 Провайдер", "providers"),
+		tgbotapi.NewInlineKeyboardButtonData("💵 Ліміт витрат", "spending_cap"),
+	), tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔊 Голосові відповіді", "voices"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	prompt, err := b.Storage.GetSystemPrompt(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання системного промпту: %v", err)
+	}
+	provider, _, _ := b.Storage.GetProvider(chatID)
+	model, _ := b.Storage.GetUserSettings(chatID)
+	voice, _ := b.Storage.GetVoice(chatID)
+
+	text := fmt.Sprintf(`⚙️ Виберіть модель, персону або провайдера:
+
+🤖 Модель: %s
+🔌 Провайдер: %s
+🎭 Системний промпт: %s
+💵 Ліміт витрат: %s
+🔊 Голосові відповіді: %s`, api.ModelDisplayName(model), providerTitle(provider), describeSystemPrompt(prompt),
+		b.describeSpendingCap(chatID), describeVoice(voice))
 
-	msg := tgbotapi.NewMessage(chatID, "⚙️ Виберіть модель GPT:")
+	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
 	if _, err := b.api.Send(msg); err != nil {
 		log.Printf("Помилка надсилання повідомлення: %v", err)
@@ -116,23 +246,23 @@ func (b *Bot) handleNewChat(chatID int64) {
 		return
 	}
 
-	model, _ := b.Storage.GetUserSettings(chatID)
-	if model == "" {
-		model = api.ModelGPT3
-		if err := b.Storage.SaveUserSettings(chatID, model); err != nil {
-			log.Printf("Помилка збереження налаштувань моделі: %v", err)
-		}
-	}
-	modelName := map[string]string{api.ModelGPT3: "GPT-3.5", api.ModelGPT4: "GPT-4"}[model]
+	model := b.currentModel(chatID)
+	modelName := api.ModelDisplayName(model)
 
-	if err := b.Storage.ClearHistory(chatID); err != nil {
-		log.Printf("Помилка очищення історії: %v", err)
-		b.sendMessage(chatID, "⚠️ Помилка очищення історії. Будь ласка, спробуйте ще раз.")
+	// Попередня розмова лишається у /chats, а нова стає активною
+	if _, err := b.Storage.NewConversation(chatID, ""); err != nil {
+		log.Printf("Помилка створення розмови: %v", err)
+		b.sendMessage(chatID, "⚠️ Помилка створення нового чату. Будь ласка, спробуйте ще раз.")
+		return
 	}
 
 	time.Sleep(100 * time.Millisecond)
-	gpt := api.NewChatGPT(apiKey)
-	gpt.SetModel(model)
+	gpt, err := b.newGPTInstance(chatID, apiKey, model)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося створити клієнт: %v", err))
+		b.sendMessage(chatID, fmt.Sprintf("❌ Помилка: %v", err))
+		return
+	}
 	b.chatGPTs.Store(chatID, gpt)
 
 	text := fmt.Sprintf(`🆕 Починаємо новий чат!
@@ -157,15 +287,39 @@ func (b *Bot) handleNewChat(chatID int64) {
 
 🤖 Поточна модель: %s
 🗑️ Видалено повідомлень: %d
+💬 Попередні розмови збережено в /chats
 
 💭 Можете починати спілкування`, modelName, deletedCount)
 	b.sendMessage(chatID, finalText)
 }
 
+// handleClearContext видаляє контекст активної розмови, щоб модель почала
+// її з чистого аркуша. Історія запитів для /search і /export лишається.
+func (b *Bot) handleClearContext(chatID int64) {
+	gpt, err := b.getOrCreateGPTInstance(chatID)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
+		return
+	}
+
+	gpt.ClearContext()
+	b.sendMessage(chatID, "🧹 Контекст розмови очищено. Історія лишається доступною в /search та /export.")
+}
+
 func (b *Bot) handleHelp(chatID int64) {
 	text := `📌 Доступні команди:
 
 /start - Почати роботу
+/forgetkey - Видалити збережений API ключ
+/redeem <код> - Активувати код з додатковими запитами
+/image <опис> - Згенерувати зображення
+/chats - Розмови: перемкнути, перейменувати, архівувати
+/clear - Очистити контекст поточної розмови
+/export - Завантажити історію у Markdown, JSON або HTML
+/search <запит> - Знайти старі відповіді в історії
+
+📄 Надішліть PDF або текстовий файл, щоб поставити запитання щодо його вмісту.
+📥 Надішліть conversations.json або zip-архів з експорту даних ChatGPT, щоб продовжити стару розмову.
 
 Просто надішліть повідомлення, і я передам його до ChatGPT!`
 	b.sendMessage(chatID, text)
@@ -190,15 +344,7 @@ func (b *Bot) customWeather(chatID int64) {
 }
 
 func (b *Bot) getWeatherForCity(chatID int64, city string) {
-	// Перевіряємо ліміт запитів
-	if !b.checkRequestLimit(chatID) {
-		logAction("ПОМИЛКА", chatID, "⚠️ Досягнуто ліміт запитів")
-		b.sendMessage(chatID, "⚠️ Ви досягли ліміту запитів на сьогодні. Використайте кодову фразу для необмеженого доступу.")
-		return
-	}
-
 	query := fmt.Sprintf("погода в %s. Відповідай українською.", city)
-	b.sendMessage(chatID, fmt.Sprintf("Запитую погоду в місті %s...", city))
 
 	gpt, err := b.getOrCreateGPTInstance(chatID)
 	if err != nil {
@@ -206,6 +352,25 @@ func (b *Bot) getWeatherForCity(chatID int64, city string) {
 		return
 	}
 
+	// Запит погоди оплачується так само, як звичайний, тож проходить ті самі перевірки витрат
+	model := gpt.GetModel()
+	if !b.checkModelPriced(chatID, model) {
+		return
+	}
+	estimate := api.EstimateCost(model, api.Usage{PromptTokens: gpt.EstimatePromptTokens(query)})
+	spent, spendingCap, allowed := b.checkSpendingCap(chatID, estimate)
+	if !allowed {
+		return
+	}
+
+	// Ліміт запитів списуємо лише тоді, коли запит точно буде виконано
+	if !b.checkRequestLimit(chatID) {
+		logAction("ПОМИЛКА", chatID, "⚠️ Досягнуто ліміт запитів")
+		b.sendMessage(chatID, limitReachedMessage)
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("Запитую погоду в місті %s...", city))
 	response, err := gpt.SendMessage(query)
 	if err != nil {
 		log.Printf("Помилка запиту погоди для міста %s: %v", city, err)
@@ -218,92 +383,162 @@ func (b *Bot) getWeatherForCity(chatID int64, city string) {
 		shortResponse = shortResponse[:97] + "..."
 	}
 	logAction("ВІДПОВІДЬ", chatID, shortResponse)
+	cost := b.recordUsage(chatID, gpt, model)
+	b.warnSpending(chatID, spent, spent+cost, spendingCap)
 
 	b.sendMessage(chatID, response)
 }
 
-func (b *Bot) handleBypassCode(chatID int64) {
-	value, _ := b.users.Load(chatID)
-	var settings UserSettings
-	if value != nil {
-		settings = value.(UserSettings)
+func (b *Bot) handleAPIKey(chatID int64, messageID int, apiKey string) {
+	// Ключ не повинен залишатися в історії чату
+	if _, err := b.api.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+		log.Printf("Помилка видалення повідомлення з ключем: %v", err)
 	}
 
-	settings.RequestCount = 0
-	settings.LastRequest = time.Now()
+	provider, err := b.userProvider(chatID, apiKey)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося створити клієнт: %v", err))
+		b.sendMessage(chatID, fmt.Sprintf("❌ Помилка: %v", err))
+		return
+	}
 
-	b.users.Store(chatID, settings)
-	b.sendMessage(chatID, "✅ Ліміт запитів знято")
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	status, err := api.ValidateKey(ctx, provider)
+	if status != api.KeyValid {
+		logAction("API_KEY", chatID, fmt.Sprintf("Ключ не пройшов перевірку (%d): %v", status, err))
+		b.sendMessage(chatID, keyStatusMessage(status))
+		return
+	}
 
-func (b *Bot) handleAPIKey(chatID int64, apiKey string) {
 	if err := b.Storage.SaveAPIKey(chatID, apiKey); err != nil {
 		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося зберегти API ключ: %v", err))
 		b.sendMessage(chatID, "❌ Помилка збереження ключа. Спробуйте ще раз.")
 		return
 	}
 
+	// Кешований екземпляр працює зі старим ключем
+	b.chatGPTs.Delete(chatID)
+
 	logAction("API_KEY", chatID, "API ключ успішно збережено")
-	b.sendMessage(chatID, "✅ API ключ успішно збережено! Тепер ви можете надсилати повідомлення.")
+	b.sendMessage(chatID, fmt.Sprintf(`✅ Ключ %s перевірено і збережено! Тепер ви можете надсилати повідомлення.
+
+🗑 Повідомлення з ключем видалено з чату. Щоб видалити ключ з бота, надішліть /forgetkey`, maskKey(apiKey)))
 }
 
-func (b *Bot) checkRequestLimit(chatID int64) bool {
-	value, exists := b.users.Load(chatID)
+func (b *Bot) handleForgetKey(chatID int64) {
+	if err := b.Storage.DeleteAPIKey(chatID); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося видалити API ключ: %v", err))
+		b.sendMessage(chatID, "❌ Помилка видалення ключа. Спробуйте ще раз.")
+		return
+	}
 
-	var settings UserSettings
-	if !exists || value == nil {
-		settings = UserSettings{
-			RequestCount: 0,
-			LastRequest:  time.Now(),
-		}
-	} else {
-		settings = value.(UserSettings)
+	b.chatGPTs.Delete(chatID)
+
+	logAction("API_KEY", chatID, "API ключ видалено")
+	b.sendMessage(chatID, "🗑 Ваш API ключ видалено. Щоб продовжити роботу, надішліть новий ключ.")
+}
+
+// maskKey показує лише префікс і останні 4 символи ключа: sk-...abcd
+func maskKey(apiKey string) string {
+	if len(apiKey) <= 7 {
+		return "sk-..."
+	}
+	return apiKey[:3] + "..." + apiKey[len(apiKey)-4:]
+}
+
+func keyStatusMessage(status api.KeyStatus) string {
+	switch status {
+	case api.KeyInvalid:
+		return "❌ Ключ недійсний: провайдер його не приймає. Перевірте, що ви скопіювали його повністю."
+	case api.KeyNoQuota:
+		return "💳 Ключ дійсний, але на рахунку немає коштів або вичерпано квоту. Поповніть баланс і надішліть ключ ще раз."
+	case api.KeyOrgRestricted:
+		return "🚫 Ключ дійсний, але організація або проєкт обмежує доступ до моделей. Перевірте налаштування доступу в кабінеті провайдера."
+	case api.KeyUnverified:
+		return "⚠️ Не вдалося перевірити ключ: провайдер недоступний. Спробуйте надіслати його ще раз пізніше."
+	default:
+		return "✅ Ключ дійсний"
 	}
+}
 
-	if time.Since(settings.LastRequest).Hours() >= 24 {
-		settings.RequestCount = 0
-		settings.LastRequest = time.Now()
+func (b *Bot) checkRequestLimit(chatID int64) bool {
+	allowed, status, err := b.Storage.ConsumeRequest(chatID)
+	if err != nil {
+		// Не блокуємо користувача через збій бази, але фіксуємо помилку
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка перевірки ліміту: %v", err))
+		return true
 	}
 
-	if settings.RequestCount >= maxRequestsPerDay {
-		return false
+	logAction("ПЕРЕВІРКА ЛІМІТУ", chatID, fmt.Sprintf("Рівень %s: використано %d, залишилось %d, дозволено %t",
+		status.Tier.Name, status.Used, status.Remaining, allowed))
+
+	return allowed
+}
+
+// requestAllowed перевіряє ліміт запитів, не списуючи його, і повідомляє
+// користувача, якщо ліміт вичерпано. Використовується перед платною
+// підготовкою запиту, сам запит списується в handleGPTRequest.
+func (b *Bot) requestAllowed(chatID int64) bool {
+	allowed, _, err := b.Storage.CanRequest(chatID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка перевірки ліміту: %v", err))
+		return true
+	}
+	if !allowed {
+		logAction("ПОМИЛКА", chatID, "⚠️ Досягнуто ліміт запитів")
+		b.sendMessage(chatID, limitReachedMessage)
 	}
+	return allowed
+}
 
-	settings.RequestCount++
-	settings.LastRequest = time.Now()
-	b.users.Store(chatID, settings)
+// newGPTInstance створює розмову з провайдером, моделлю і системним промптом
+// з налаштувань користувача та прив'язує її до активної розмови у сховищі.
+func (b *Bot) newGPTInstance(chatID int64, apiKey, model string) (*api.ChatGPT, error) {
+	provider, err := b.userProvider(chatID, apiKey)
+	if err != nil {
+		return nil, err
+	}
 
-	logAction("ПЕРЕВІРКА ЛІМІТУ", chatID, fmt.Sprintf("Запитів сьогодні: %d/%d", settings.RequestCount, maxRequestsPerDay))
+	systemPrompt, err := b.Storage.GetSystemPrompt(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання системного промпту: %v", err)
+	}
 
-	return true
+	gpt := api.NewChatGPTWithProvider(provider)
+	gpt.SetModel(model)
+	gpt.SetSystemPrompt(systemPrompt)
+	if err := b.attachConversation(chatID, gpt); err != nil {
+		log.Printf("Помилка відновлення розмови: %v", err)
+	}
+	return gpt, nil
 }
 
 func (b *Bot) getOrCreateGPTInstance(chatID int64) (*api.ChatGPT, error) {
-	gptInstance, _ := b.chatGPTs.LoadOrStore(chatID, func() interface{} {
-		apiKey, err := b.Storage.GetAPIKey(chatID)
-		if err != nil {
-			return nil
-		}
-		model, _ := b.Storage.GetUserSettings(chatID)
-		if model == "" {
-			model = api.ModelGPT3
-			if err := b.Storage.SaveUserSettings(chatID, model); err != nil {
-				log.Printf("Помилка збереження налаштувань моделі: %v", err)
-			}
-		}
-		gpt := api.NewChatGPT(apiKey)
-		gpt.SetModel(model)
-		return gpt
-	}())
+	if gptInstance, ok := b.chatGPTs.Load(chatID); ok {
+		return gptInstance.(*api.ChatGPT), nil
+	}
 
-	if gptInstance == nil {
+	apiKey, err := b.Storage.GetAPIKey(chatID)
+	if err != nil || apiKey == "" {
 		return nil, fmt.Errorf("API ключ не знайдено")
 	}
 
+	gpt, err := b.newGPTInstance(chatID, apiKey, b.currentModel(chatID))
+	if err != nil {
+		return nil, err
+	}
+
+	// Інша горутина могла створити екземпляр раніше - використовуємо його
+	gptInstance, _ := b.chatGPTs.LoadOrStore(chatID, gpt)
 	return gptInstance.(*api.ChatGPT), nil
 }
 
-func (b *Bot) handleGPTRequest(chatID int64, text string) {
+// handleGPTRequest надсилає запит моделі користувача. Запити із зображеннями
+// виконуються моделлю, що підтримує зображення. Ліміт запитів списується
+// лише після перевірки ліміту витрат, тож відхилений запит квоти не коштує.
+func (b *Bot) handleGPTRequest(chatID int64, text string, images ...api.Image) {
 	gpt, err := b.getOrCreateGPTInstance(chatID)
 	if err != nil {
 		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
@@ -313,17 +548,67 @@ func (b *Bot) handleGPTRequest(chatID int64, text string) {
 	currentModel, _ := b.Storage.GetUserSettings(chatID)
 	if currentModel != "" && gpt.GetModel() != currentModel {
 		gpt.SetModel(currentModel)
-		gpt.ClearContext()
+		gpt.ResetContext()
 	}
 
-	model, _ := b.Storage.GetUserSettings(chatID)
-	modelName := map[string]string{api.ModelGPT3: "GPT-3.5", api.ModelGPT4: "GPT-4"}[model]
-	logAction("ЗАПИТ", chatID, fmt.Sprintf("[%s] %s", modelName, text))
+	model := gpt.GetModel()
+	if len(images) > 0 {
+		provider, _, _ := b.Storage.GetProvider(chatID)
+		model = api.VisionModel(provider, model)
+	}
+
+	modelName := api.ModelDisplayName(model)
+	logAction("ЗАПИТ", chatID, fmt.Sprintf("[%s] %s (зображень: %d)", modelName, text, len(images)))
+
+	if !b.checkModelPriced(chatID, model) {
+		return
+	}
+
+	// Довжина відповіді наперед невідома, тому оцінюємо лише вихідний запит.
+	// Ембединг запитання до файлу теж платний, тож ліміти перевіряємо до нього
+	promptTokens := gpt.EstimatePromptTokensFor(model, text, images...)
+	estimate := api.EstimateCost(model, api.Usage{PromptTokens: promptTokens})
+	spent, spendingCap, allowed := b.checkSpendingCap(chatID, estimate)
+	if !allowed || !b.requestAllowed(chatID) {
+		return
+	}
 
-	response, err := gpt.SendMessage(text)
+	// Запитання до завантаженого файлу доповнюються його фрагментами
+	var grounding string
+	if len(images) == 0 {
+		grounding = b.documentGrounding(chatID, gpt, text)
+	}
+	if grounding != "" {
+		estimate = api.EstimateCost(model, api.Usage{PromptTokens: promptTokens + api.CountTokens(grounding)})
+		if spent, spendingCap, allowed = b.checkSpendingCap(chatID, estimate); !allowed {
+			return
+		}
+	}
+
+	if !b.checkRequestLimit(chatID) {
+		logAction("ПОМИЛКА", chatID, "⚠️ Досягнуто ліміт запитів")
+		b.sendMessage(chatID, limitReachedMessage)
+		return
+	}
+
+	stream, err := b.newStreamMessage(chatID)
+	if err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+		return
+	}
+
+	var response string
+	switch {
+	case len(images) > 0:
+		response, err = gpt.SendImageStream(model, text, images, stream.Append)
+	case grounding != "":
+		response, err = gpt.SendGroundedStream(text, grounding, stream.Append)
+	default:
+		response, err = gpt.SendMessageStream(text, stream.Append)
+	}
 	if err != nil {
 		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка GPT: %v", err))
-		b.sendMessage(chatID, fmt.Sprintf("❌ Помилка: %v", err))
+		stream.Fail(fmt.Sprintf("❌ Помилка: %v", err))
 		return
 	}
 
@@ -332,80 +617,86 @@ func (b *Bot) handleGPTRequest(chatID int64, text string) {
 		shortResponse = shortResponse[:97] + "..."
 	}
 	logAction("ВІДПОВІДЬ", chatID, shortResponse)
+	if dropped := gpt.DroppedTokens(); dropped > 0 {
+		logAction("КОНТЕКСТ", chatID, fmt.Sprintf("Не вмістилося токенів історії: %d", dropped))
+	}
+	cost := b.recordUsage(chatID, gpt, model)
 
-	if err := b.Storage.SaveToHistory(chatID, text, response); err != nil {
+	historyText := text
+	if len(images) > 0 {
+		historyText = "🖼 " + text
+	}
+	if err := b.Storage.SaveToHistory(chatID, gpt.ConversationID(), historyText, response); err != nil {
 		log.Printf("Помилка збереження в історію: %v", err)
 	}
 
-	b.sendMessage(chatID, response, true)
+	if doc, ok := detectDocument(response); ok {
+		b.sendResponseDocument(chatID, stream, doc, response)
+	} else {
+		stream.Finish(response)
+	}
+	b.sendVoiceReply(chatID, gpt, response)
+	b.warnSpending(chatID, spent, spent+cost, spendingCap)
+}
+
+// recordUsage зберігає токени і оціночну вартість останнього запиту та
+// повертає цю вартість.
+func (b *Bot) recordUsage(chatID int64, gpt *api.ChatGPT, model string) float64 {
+	usage := gpt.LastUsage()
+	cost := api.EstimateCost(model, usage)
+	logAction("ТОКЕНИ", chatID, fmt.Sprintf("запит=%d, відповідь=%d, ~$%.5f", usage.PromptTokens, usage.CompletionTokens, cost))
+
+	if err := b.Storage.RecordUsage(chatID, model, usage.PromptTokens, usage.CompletionTokens, cost); err != nil {
+		log.Printf("Помилка збереження використання: %v", err)
+	}
+	return cost
 }
 
 func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 	chatID := callback.Message.Chat.ID
 	logAction("CALLBACK", chatID, fmt.Sprintf("Дія: %s", callback.Data))
 
+	if b.checkBanned(chatID, callback.From.ID) {
+		logAction("ЗАБЛОКОВАНО", chatID, "Callback від заблокованого користувача")
+		return
+	}
+
 	callbackResponse := tgbotapi.NewCallback(callback.ID, "")
 	if _, err := b.api.Request(callbackResponse); err != nil {
 		log.Printf("Помилка відповіді на callback: %v", err)
 	}
 
-	// Визначаємо карти відповідностей на рівні пакету
-	var (
-		modelMap = map[string]string{
-			"gpt3": api.ModelGPT3,
-			"gpt4": api.ModelGPT4,
-		}
-		readableModelMap = map[string]string{
-			"gpt3": "GPT-3.5",
-			"gpt4": "GPT-4",
-		}
-	)
-
 	switch callback.Data {
-	case "model_gpt3", "model_gpt4":
-		parts := strings.Split(callback.Data, "_")
-		if len(parts) < 2 {
-			logAction("ПОМИЛКА", chatID, "Некоректний формат callback.Data")
-			return
-		}
-		currentModel := parts[1]
-
-		// Перевіряємо, чи є така модель
-		fullModelName, exists := modelMap[currentModel]
-		if !exists {
-			logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідома модель: %s", currentModel))
-			return
-		}
-
-		// Отримуємо поточну модель користувача
-		oldModel, err := b.Storage.GetUserSettings(chatID)
-		logAction("НАЛАШТУВАННЯ", chatID, fmt.Sprintf("Поточна модель: %s, Нова модель: %s", oldModel, fullModelName))
-
-		// Якщо модель вже встановлена
-		if err == nil && oldModel == fullModelName {
-			logAction("МОДЕЛЬ", chatID, fmt.Sprintf("Спроба зміни на поточну модель (%s)", fullModelName))
-			b.sendMessage(chatID, fmt.Sprintf("ℹ️ %s вже є поточною моделлю", readableModelMap[currentModel]))
-			return
-		}
-
-		// Збереження нової моделі
-		if err := b.Storage.SaveUserSettings(chatID, fullModelName); err != nil {
-			logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося змінити модель: %v", err))
-			b.sendMessage(chatID, "❌ Помилка зміни моделі")
-			return
-		}
-
-		// Оновлення GPT-екземпляра
-		if gptInstance, exists := b.chatGPTs.Load(chatID); exists {
-			if gpt, ok := gptInstance.(*api.ChatGPT); ok {
-				gpt.SetModel(fullModelName)
-				gpt.ClearContext()
-				b.chatGPTs.Store(chatID, gpt)
-			}
+	case "noop":
+	case "providers":
+		b.handleProviderMenu(chatID)
+	case "spending_cap":
+		b.handleSpendingCapMenu(chatID)
+	case "voices":
+		b.handleVoiceMenu(chatID)
+	default:
+		if strings.HasPrefix(callback.Data, "persona_") {
+			b.handlePersonaCallback(chatID, strings.TrimPrefix(callback.Data, "persona_"))
+		} else if strings.HasPrefix(callback.Data, "provider_") {
+			b.handleProviderCallback(chatID, strings.TrimPrefix(callback.Data, "provider_"))
+		} else if strings.HasPrefix(callback.Data, "models_page_") {
+			b.handleModelPage(chatID, callback.Message.MessageID, callback.Data)
+		} else if strings.HasPrefix(callback.Data, "model:") {
+			b.handleModelCallback(chatID, strings.TrimPrefix(callback.Data, "model:"))
+		} else if strings.HasPrefix(callback.Data, "view_inline_") {
+			b.handleViewInline(chatID, callback.Data)
+		} else if strings.HasPrefix(callback.Data, "image_") {
+			b.handleImageCallback(chatID, callback.Message.MessageID, strings.TrimPrefix(callback.Data, "image_"))
+		} else if strings.HasPrefix(callback.Data, "search_") {
+			b.handleSearchCallback(chatID, strings.TrimPrefix(callback.Data, "search_"))
+		} else if strings.HasPrefix(callback.Data, "import_") {
+			b.handleImportCallback(chatID, callback.Message.MessageID, strings.TrimPrefix(callback.Data, "import_"))
+		} else if strings.HasPrefix(callback.Data, "export_") {
+			b.handleExportCallback(chatID, strings.TrimPrefix(callback.Data, "export_"))
+		} else if strings.HasPrefix(callback.Data, "chat_") {
+			b.handleChatCallback(chatID, callback.Message.MessageID, strings.TrimPrefix(callback.Data, "chat_"))
+		} else if strings.HasPrefix(callback.Data, "voice_") {
+			b.handleVoiceCallback(chatID, strings.TrimPrefix(callback.Data, "voice_"))
 		}
-
-		// Логування і повідомлення користувачу
-		logAction("МОДЕЛЬ", chatID, fmt.Sprintf("Зміна на %s", readableModelMap[currentModel]))
-		b.sendMessage(chatID, fmt.Sprintf("✅ Модель змінено на %s", readableModelMap[currentModel]))
 	}
 }
@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"GPTGRAMM/internal/storage"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Обмеження dall-e-3 на довжину опису
+	maxImagePromptLen = 4000
+	// Telegram обмежує підпис до фото 1024 символами
+	photoCaptionLimit = 1024
+)
+
+var imageSizeTitles = map[string]string{
+	"1024x1024": "⬜ Квадрат",
+	"1792x1024": "▭ Альбомне",
+	"1024x1792": "▯ Портретне",
+}
+
+// handleImageCommand запам'ятовує опис і пропонує обрати розмір і якість.
+func (b *Bot) handleImageCommand(chatID int64, prompt string) {
+	if prompt == "" {
+		b.sendMessage(chatID, "Використання: /image <опис зображення>\nНаприклад: /image кіт-астронавт у стилі акварелі")
+		return
+	}
+	if utf8.RuneCountInString(prompt) > maxImagePromptLen {
+		b.sendMessage(chatID, fmt.Sprintf("⚠️ Опис задовгий, максимум %d символів.", maxImagePromptLen))
+		return
+	}
+
+	if _, err := b.getOrCreateGPTInstance(chatID); err != nil {
+		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
+		return
+	}
+
+	b.users.Store(fmt.Sprintf("%d_image_prompt", chatID), prompt)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, quality := range []string{api.ImageQualityStandard, api.ImageQualityHD} {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, size := range api.ImageSizes {
+			title := imageSizeTitles[size]
+			if quality == api.ImageQualityHD {
+				title += " HD"
+			}
+			title += fmt.Sprintf(" $%.2f", api.ImageCost(size, quality))
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("image_%s_%s", size, quality)))
+		}
+		rows = append(rows, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "🎨 Оберіть розмір і якість зображення:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+	}
+}
+
+// handleImageCallback генерує зображення з обраними параметрами.
+func (b *Bot) handleImageCallback(chatID int64, messageID int, data string) {
+	size, quality, ok := strings.Cut(data, "_")
+	if !ok || !slices.Contains(api.ImageSizes, size) || (quality != api.ImageQualityStandard && quality != api.ImageQualityHD) {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідомі параметри зображення: %s", data))
+		return
+	}
+
+	key := fmt.Sprintf("%d_image_prompt", chatID)
+	value, ok := b.users.LoadAndDelete(key)
+	if !ok {
+		b.sendMessage(chatID, "⚠️ Опис зображення не знайдено, надішліть /image <опис> ще раз.")
+		return
+	}
+	prompt := value.(string)
+
+	// Прибираємо кнопки, щоб зображення не генерувалося повторно
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("🎨 Генерую зображення %s (%s)...", size, quality))
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("Помилка редагування повідомлення: %v", err)
+	}
+
+	gpt, err := b.getOrCreateGPTInstance(chatID)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
+		return
+	}
+
+	cost := api.ImageCost(size, quality)
+	spent, spendingCap, allowed := b.checkSpendingCap(chatID, cost)
+	if !allowed {
+		return
+	}
+
+	if !b.checkRequestLimit(chatID) {
+		logAction("ПОМИЛКА", chatID, "⚠️ Досягнуто ліміт запитів")
+		b.sendMessage(chatID, limitReachedMessage)
+		return
+	}
+
+	action := tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadPhoto)
+	if _, err := b.api.Request(action); err != nil {
+		log.Printf("Помилка надсилання дії: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	image, err := gpt.GenerateImage(ctx, prompt, size, quality)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка генерації зображення: %v", err))
+		b.sendMessage(chatID, fmt.Sprintf("❌ Не вдалося згенерувати зображення: %v", err))
+		return
+	}
+
+	// Генерацію оплачено, тож записуємо її до надсилання: інакше збій
+	// Telegram приховав би витрати від ліміту
+	imageID, err := b.Storage.SaveGeneratedImage(chatID, storage.GeneratedImage{
+		Prompt:        prompt,
+		RevisedPrompt: image.RevisedPrompt,
+		Model:         api.ImageModel,
+		Size:          size,
+		Quality:       quality,
+		Cost:          cost,
+	})
+	if err != nil {
+		log.Printf("Помилка збереження зображення: %v", err)
+	}
+	logAction("ЗОБРАЖЕННЯ", chatID, fmt.Sprintf("%s %s ~$%.2f: %s", size, quality, cost, prompt))
+	b.warnSpending(chatID, spent, spent+cost, spendingCap)
+
+	caption := prompt
+	if image.RevisedPrompt != "" {
+		caption = image.RevisedPrompt
+	}
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "image.png", Bytes: image.Data})
+	photo.Caption = truncateText(caption, photoCaptionLimit)
+
+	sent, err := b.api.Send(photo)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка надсилання зображення: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося надіслати зображення")
+		return
+	}
+	queue := b.getMessageQueue(chatID)
+	queue.Add(sent.MessageID)
+
+	if len(sent.Photo) > 0 && imageID != 0 {
+		if err := b.Storage.SetGeneratedImageFileID(imageID, sent.Photo[len(sent.Photo)-1].FileID); err != nil {
+			log.Printf("Помилка збереження зображення: %v", err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/importer"
+	"GPTGRAMM/internal/storage"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	importsPerPage = 8
+	// Скільки розібраний експорт чекає на вибір розмови, перш ніж звільнити пам'ять
+	pendingImportTTL = 15 * time.Minute
+)
+
+// pendingImportList - розмови експорту, з яких користувач ще не обрав
+type pendingImportList struct {
+	conversations []importer.Conversation
+}
+
+// handleChatGPTExport розбирає експорт даних ChatGPT і пропонує обрати
+// розмову для імпорту.
+func (b *Bot) handleChatGPTExport(chatID int64, name string, data []byte) {
+	conversations, err := importer.ReadChatGPTExport(name, data)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка розбору експорту ChatGPT: %v", err))
+		b.sendMessage(chatID, fmt.Sprintf("❌ Не вдалося прочитати експорт ChatGPT: %v", err))
+		return
+	}
+
+	logAction("ІМПОРТ", chatID, fmt.Sprintf("Знайдено розмов: %d", len(conversations)))
+	key := fmt.Sprintf("%d_import", chatID)
+	pending := &pendingImportList{conversations: conversations}
+	b.users.Store(key, pending)
+	time.AfterFunc(pendingImportTTL, func() {
+		// Новіший експорт міг замінити цей - його не чіпаємо
+		b.users.CompareAndDelete(key, pending)
+	})
+	b.handleImportPage(chatID, 0, 0)
+}
+
+// handleImportPage показує сторінку розмов з експорту. Якщо messageID не
+// нуль, редагує існуюче повідомлення замість надсилання нового.
+func (b *Bot) handleImportPage(chatID int64, messageID int, page int) {
+	conversations, ok := b.pendingImport(chatID)
+	if !ok {
+		return
+	}
+
+	pages := (len(conversations) + importsPerPage - 1) / importsPerPage
+	if page < 0 || page >= pages {
+		page = 0
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	end := min((page+1)*importsPerPage, len(conversations))
+	for i := page * importsPerPage; i < end; i++ {
+		c := conversations[i]
+		title := truncateText(importTitle(c), chatButtonTitle)
+		if !c.UpdatedAt.IsZero() {
+			title = fmt.Sprintf("%s · %s", title, c.UpdatedAt.Format("02.01.06"))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("import_pick_%d", i)),
+		))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", fmt.Sprintf("import_page_%d", page-1)))
+	}
+	if pages > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, pages), "noop"))
+	}
+	if page < pages-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", fmt.Sprintf("import_page_%d", page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Скасувати", "import_cancel")))
+
+	text := fmt.Sprintf(`📥 Імпорт з ChatGPT
+
+Знайдено розмов: %d. Оберіть розмову, яку хочете продовжити - вона стане новою розмовою в /chats.`, len(conversations))
+	b.sendOrEditMenu(chatID, messageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// handleImportCallback обробляє кнопки списку імпорту: import_page_<n>,
+// import_pick_<i> та import_cancel.
+func (b *Bot) handleImportCallback(chatID int64, messageID int, data string) {
+	key := fmt.Sprintf("%d_import", chatID)
+	if data == "cancel" {
+		b.users.Delete(key)
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, "📥 Імпорт скасовано")
+		if _, err := b.api.Request(edit); err != nil {
+			log.Printf("Помилка редагування повідомлення: %v", err)
+		}
+		return
+	}
+
+	action, rawIndex, _ := strings.Cut(data, "_")
+	index, err := strconv.Atoi(rawIndex)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, "Некоректний формат callback.Data")
+		return
+	}
+
+	switch action {
+	case "page":
+		b.handleImportPage(chatID, messageID, index)
+	case "pick":
+		conversations, ok := b.pendingImport(chatID)
+		if !ok {
+			return
+		}
+		if index < 0 || index >= len(conversations) {
+			logAction("ПОМИЛКА", chatID, fmt.Sprintf("Некоректний номер розмови: %d", index))
+			return
+		}
+		b.users.Delete(key)
+		b.importConversation(chatID, messageID, conversations[index])
+	default:
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідома дія імпорту: %s", action))
+	}
+}
+
+// importConversation зберігає розмову з експорту як нову активну розмову,
+// повідомлення якої стають контекстом моделі.
+func (b *Bot) importConversation(chatID int64, messageID int, conversation importer.Conversation) {
+	messages := make([]storage.Message, 0, len(conversation.Messages))
+	for _, m := range conversation.Messages {
+		messages = append(messages, storage.Message{Role: m.Role, Content: m.Content})
+	}
+
+	exchanges := conversation.Exchanges()
+	history := make([]storage.HistoryEntry, 0, len(exchanges))
+	for _, e := range exchanges {
+		history = append(history, storage.HistoryEntry{Message: e.Prompt, Response: e.Response, CreatedAt: e.CreatedAt})
+	}
+
+	title := truncateText(importTitle(conversation), maxChatTitle)
+	conversationID, err := b.Storage.ImportConversation(chatID, title, messages, history)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка імпорту розмови: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося імпортувати розмову")
+		return
+	}
+	b.reattachConversation(chatID)
+
+	logAction("ІМПОРТ", chatID, fmt.Sprintf("Імпортовано #%d %s: повідомлень %d", conversationID, title, len(messages)))
+	text := fmt.Sprintf(`📥 Імпортовано розмову «%s»
+💬 Повідомлень: %d
+
+Розмова стала активною - просто продовжуйте писати. Усі розмови доступні в /chats.`, title, len(messages))
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("Помилка редагування повідомлення: %v", err)
+		b.sendMessage(chatID, text)
+	}
+}
+
+func (b *Bot) pendingImport(chatID int64) ([]importer.Conversation, bool) {
+	value, ok := b.users.Load(fmt.Sprintf("%d_import", chatID))
+	if !ok {
+		b.sendMessage(chatID, "⚠️ Список розмов застарів, надішліть файл експорту ще раз.")
+		return nil, false
+	}
+	return value.(*pendingImportList).conversations, true
+}
+
+// importTitle повертає назву розмови з експорту
+func importTitle(c importer.Conversation) string {
+	if c.Title != "" {
+		return c.Title
+	}
+	return "Розмова з ChatGPT"
+}
@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	modelsPerPage   = 8
+	modelCatalogTTL = 30 * time.Minute
+	// Telegram обмежує callback_data 64 байтами
+	maxCallbackData = 64
+)
+
+// currentModel повертає модель користувача, а якщо її ще не обрано -
+// модель за замовчуванням для його провайдера.
+func (b *Bot) currentModel(chatID int64) string {
+	model, _ := b.Storage.GetUserSettings(chatID)
+	if model != "" {
+		return model
+	}
+
+	provider, _, _ := b.Storage.GetProvider(chatID)
+	model = api.DefaultModel(provider)
+	if model != "" {
+		if err := b.Storage.SaveUserSettings(chatID, model); err != nil {
+			log.Printf("Помилка збереження налаштувань моделі: %v", err)
+		}
+	}
+	return model
+}
+
+// availableModels повертає моделі, доступні з ключем користувача, через кеш каталогу.
+func (b *Bot) availableModels(chatID int64) ([]string, error) {
+	gpt, err := b.getOrCreateGPTInstance(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, _ := b.Storage.GetAPIKey(chatID)
+	provider, baseURL, _ := b.Storage.GetProvider(chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	return b.models.Models(ctx, strings.Join([]string{provider, baseURL, apiKey}, "|"), gpt)
+}
+
+// handleModelMenu показує сторінку списку моделей. Якщо messageID не нуль,
+// редагує існуюче повідомлення замість надсилання нового.
+func (b *Bot) handleModelMenu(chatID int64, messageID int, page int) {
+	models, err := b.availableModels(chatID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося отримати список моделей: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося отримати список моделей. Перевірте API ключ і провайдера.")
+		return
+	}
+	if len(models) == 0 {
+		b.sendMessage(chatID, "ℹ️ Провайдер не повідомив жодної доступної моделі")
+		return
+	}
+
+	pages := (len(models) + modelsPerPage - 1) / modelsPerPage
+	if page < 0 || page >= pages {
+		page = 0
+	}
+
+	current := b.currentModel(chatID)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+
+	end := min((page+1)*modelsPerPage, len(models))
+	for _, model := range models[page*modelsPerPage : end] {
+		data := "model:" + model
+		if len(data) > maxCallbackData {
+			continue
+		}
+
+		title := api.ModelDisplayName(model)
+		if model == current {
+			title = "✅ " + title
+		}
+
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(title, data))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	if pages > 1 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", fmt.Sprintf("models_page_%d", page-1)))
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, pages), "noop"))
+		if page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", fmt.Sprintf("models_page_%d", page+1)))
+		}
+		rows = append(rows, nav)
+	}
+
+	text := fmt.Sprintf("🤖 Поточна модель: %s\n\nОберіть модель:", api.ModelDisplayName(current))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+		if _, err := b.api.Request(edit); err != nil {
+			log.Printf("Помилка редагування повідомлення: %v", err)
+		}
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+	}
+}
+
+func (b *Bot) handleModelPage(chatID int64, messageID int, data string) {
+	page, err := strconv.Atoi(strings.TrimPrefix(data, "models_page_"))
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, "Некоректний формат callback.Data")
+		return
+	}
+	b.handleModelMenu(chatID, messageID, page)
+}
+
+func (b *Bot) handleModelCallback(chatID int64, model string) {
+	// Перевіряємо, чи доступна модель з ключем користувача
+	models, err := b.availableModels(chatID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося отримати список моделей: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося перевірити модель. Спробуйте пізніше.")
+		return
+	}
+
+	found := false
+	for _, m := range models {
+		if m == model {
+			found = true
+			break
+		}
+	}
+	if !found {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідома модель: %s", model))
+		b.sendMessage(chatID, "⚠️ Ця модель недоступна з вашим ключем")
+		return
+	}
+
+	displayName := api.ModelDisplayName(model)
+
+	// Отримуємо поточну модель користувача
+	oldModel, err := b.Storage.GetUserSettings(chatID)
+	logAction("НАЛАШТУВАННЯ", chatID, fmt.Sprintf("Поточна модель: %s, Нова модель: %s", oldModel, model))
+
+	// Якщо модель вже встановлена
+	if err == nil && oldModel == model {
+		logAction("МОДЕЛЬ", chatID, fmt.Sprintf("Спроба зміни на поточну модель (%s)", model))
+		b.sendMessage(chatID, fmt.Sprintf("ℹ️ %s вже є поточною моделлю", displayName))
+		return
+	}
+
+	// Збереження нової моделі
+	if err := b.Storage.SaveUserSettings(chatID, model); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Не вдалося змінити модель: %v", err))
+		b.sendMessage(chatID, "❌ Помилка зміни моделі")
+		return
+	}
+
+	// Оновлення GPT-екземпляра
+	if gptInstance, exists := b.chatGPTs.Load(chatID); exists {
+		if gpt, ok := gptInstance.(*api.ChatGPT); ok {
+			gpt.SetModel(model)
+			gpt.ResetContext()
+		}
+	}
+
+	logAction("МОДЕЛЬ", chatID, fmt.Sprintf("Зміна на %s", displayName))
+	b.sendMessage(chatID, fmt.Sprintf("✅ Модель змінено на %s", displayName))
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"fmt"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	stateAwaitingPrompt = "awaiting_system_prompt"
+	maxSystemPromptLen  = 2000
+)
+
+// Persona - вбудований набір інструкцій для моделі
+type Persona struct {
+	ID     string
+	Title  string
+	Prompt string
+}
+
+var personas = []Persona{
+	{
+		ID:     "translator",
+		Title:  "🌍 Перекладач",
+		Prompt: "You are a professional translator. Translate the user's text: Ukrainian into English, any other language into Ukrainian. Preserve meaning, tone and formatting. Reply with the translation only.",
+	},
+	{
+		ID:     "reviewer",
+		Title:  "🧑‍💻 Код-рев'юер",
+		Prompt: "You are a senior software engineer doing code review. Point out bugs, security issues, performance problems and unclear code, ordered by severity. Suggest concrete fixes with short code snippets. Answer in the user's language.",
+	},
+	{
+		ID:     "tutor",
+		Title:  "🎓 Репетитор",
+		Prompt: "You are a patient tutor. Explain concepts step by step with simple examples, check understanding with a short question at the end, and never just give away the final answer to homework problems. Answer in the user's language.",
+	},
+}
+
+func findPersona(id string) (Persona, bool) {
+	for _, p := range personas {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
+
+// personaKeyboard - кнопки вибору персони для меню налаштувань
+func personaKeyboard() [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, p := range personas {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.Title, "persona_"+p.ID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✍️ Власний промпт", "persona_custom"),
+		tgbotapi.NewInlineKeyboardButtonData("🚫 Без промпту", "persona_none"),
+	))
+	return rows
+}
+
+// describeSystemPrompt повертає коротку назву поточного промпту для меню
+func describeSystemPrompt(prompt string) string {
+	if prompt == "" {
+		return "не задано"
+	}
+	for _, p := range personas {
+		if p.Prompt == prompt {
+			return p.Title
+		}
+	}
+
+	if utf8.RuneCountInString(prompt) > 60 {
+		prompt = string([]rune(prompt)[:57]) + "..."
+	}
+	return fmt.Sprintf("власний (%s)", prompt)
+}
+
+func (b *Bot) handlePersonaCallback(chatID int64, personaID string) {
+	switch personaID {
+	case "custom":
+		b.users.Store(fmt.Sprintf("%d_state", chatID), stateAwaitingPrompt)
+		b.sendMessage(chatID, fmt.Sprintf("✍️ Надішліть текст системного промпту (до %d символів).", maxSystemPromptLen))
+	case "none":
+		if b.applySystemPrompt(chatID, "") {
+			b.sendMessage(chatID, "✅ Системний промпт вимкнено")
+		}
+	default:
+		persona, ok := findPersona(personaID)
+		if !ok {
+			logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідома персона: %s", personaID))
+			return
+		}
+		if b.applySystemPrompt(chatID, persona.Prompt) {
+			b.sendMessage(chatID, fmt.Sprintf("✅ Обрано персону %s", persona.Title))
+		}
+	}
+}
+
+func (b *Bot) handleCustomPrompt(chatID int64, prompt string) {
+	if utf8.RuneCountInString(prompt) > maxSystemPromptLen {
+		b.sendMessage(chatID, fmt.Sprintf("⚠️ Промпт задовгий, максимум %d символів. Спробуйте ще раз через ⚙️ Налаштування.", maxSystemPromptLen))
+		return
+	}
+
+	if b.applySystemPrompt(chatID, prompt) {
+		b.sendMessage(chatID, "✅ Власний системний промпт збережено")
+	}
+}
+
+// applySystemPrompt зберігає промпт і оновлює вже створений GPT-екземпляр.
+func (b *Bot) applySystemPrompt(chatID int64, prompt string) bool {
+	if err := b.Storage.SaveSystemPrompt(chatID, prompt); err != nil {
+		b.sendMessage(chatID, "❌ Помилка збереження промпту")
+		return false
+	}
+
+	if gptInstance, exists := b.chatGPTs.Load(chatID); exists {
+		gptInstance.(*api.ChatGPT).SetSystemPrompt(prompt)
+	}
+
+	logAction("ПРОМПТ", chatID, describeSystemPrompt(prompt))
+	return true
+}
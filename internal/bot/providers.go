@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const stateAwaitingBaseURL = "awaiting_base_url"
+
+var providerTitles = map[string]string{
+	api.ProviderOpenAI:     "OpenAI",
+	api.ProviderAnthropic:  "Anthropic",
+	api.ProviderOllama:     "Ollama",
+	api.ProviderCompatible: "OpenAI-сумісний сервер",
+}
+
+func providerTitle(name string) string {
+	if name == "" {
+		name = api.ProviderOpenAI
+	}
+	if title, ok := providerTitles[name]; ok {
+		return title
+	}
+	return name
+}
+
+func (b *Bot) handleProviderMenu(chatID int64) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("OpenAI", "provider_"+api.ProviderOpenAI),
+			tgbotapi.NewInlineKeyboardButtonData("Anthropic", "provider_"+api.ProviderAnthropic),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Ollama", "provider_"+api.ProviderOllama),
+			tgbotapi.NewInlineKeyboardButtonData("Свій сервер", "provider_"+api.ProviderCompatible),
+		),
+	)
+
+	current, baseURL, _ := b.Storage.GetProvider(chatID)
+	text := fmt.Sprintf("🔌 Поточний провайдер: %s", providerTitle(current))
+	if baseURL != "" {
+		text += fmt.Sprintf("\n🌐 Адреса: %s", baseURL)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text+"\n\nОберіть провайдера:")
+	msg.ReplyMarkup = keyboard
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+	}
+}
+
+func (b *Bot) handleProviderCallback(chatID int64, provider string) {
+	if _, ok := providerTitles[provider]; !ok {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідомий провайдер: %s", provider))
+		return
+	}
+
+	// Сервери на машині бота чи в її мережі доступні лише адміністраторам
+	switch {
+	case provider == api.ProviderOllama && !b.isAdmin(chatID):
+		b.sendMessage(chatID, "🚫 Ollama доступна лише адміністраторам бота")
+		return
+	case provider == api.ProviderCompatible && !b.isAdmin(chatID) && len(b.providerHosts) == 0:
+		b.sendMessage(chatID, "🚫 Власні сервери доступні лише адміністраторам бота")
+		return
+	}
+
+	if provider == api.ProviderCompatible {
+		b.users.Store(fmt.Sprintf("%d_state", chatID), stateAwaitingBaseURL)
+		b.sendMessage(chatID, "🌐 Надішліть адресу OpenAI-сумісного API, наприклад http://localhost:8000/v1")
+		return
+	}
+
+	b.switchProvider(chatID, provider, "", api.DefaultModel(provider))
+}
+
+func (b *Bot) handleBaseURL(chatID int64, text string) {
+	baseURL := strings.TrimSpace(text)
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		b.sendMessage(chatID, "⚠️ Некоректна адреса. Оберіть провайдера ще раз через ⚙️ Налаштування.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := b.checkProviderURL(ctx, chatID, parsed); err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Адресу %s відхилено: %v", baseURL, err))
+		b.sendMessage(chatID, fmt.Sprintf("🚫 Цю адресу використати не можна: %v", err))
+		return
+	}
+
+	// Модель за замовчуванням беремо з переліку, який повертає сервер
+	apiKey, _ := b.Storage.GetAPIKey(chatID)
+	model := ""
+	models, err := b.compatibleProvider(chatID, baseURL, apiKey).ListModels(ctx)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Сервер %s не повернув список моделей: %v", baseURL, err))
+	} else {
+		for _, m := range models {
+			if api.IsChatModel(m) {
+				model = m
+				break
+			}
+		}
+	}
+
+	b.switchProvider(chatID, api.ProviderCompatible, baseURL, model)
+}
+
+// checkProviderURL дозволяє адміністраторам будь-яку адресу, а іншим
+// користувачам - лише хости з PROVIDER_HOSTS, що ведуть у публічну мережу.
+func (b *Bot) checkProviderURL(ctx context.Context, chatID int64, baseURL *url.URL) error {
+	if b.isAdmin(chatID) {
+		return nil
+	}
+
+	host := strings.ToLower(baseURL.Hostname())
+	if !b.providerHosts[host] {
+		return fmt.Errorf("хост %s не входить до дозволених", host)
+	}
+	return api.CheckPublicHost(ctx, host)
+}
+
+// compatibleProvider створює клієнт OpenAI-сумісного сервера. Для всіх, крім
+// адміністраторів, з'єднання з локальними та приватними адресами заборонені.
+func (b *Bot) compatibleProvider(chatID int64, baseURL, apiKey string) *api.OpenAICompatible {
+	provider := api.NewOpenAICompatible(baseURL, apiKey)
+	if !b.isAdmin(chatID) {
+		provider.PublicOnly()
+	}
+	return provider
+}
+
+// userProvider створює клієнт провайдера, обраного користувачем. Адресу
+// могли зберегти до обмеження власних серверів, тож для всіх, крім
+// адміністраторів, вона перевіряється перед кожним використанням ключа.
+func (b *Bot) userProvider(chatID int64, apiKey string) (api.Provider, error) {
+	providerName, baseURL, err := b.Storage.GetProvider(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання провайдера: %v", err)
+	}
+
+	if !b.isAdmin(chatID) {
+		switch providerName {
+		case api.ProviderOllama:
+			return nil, fmt.Errorf("Ollama доступна лише адміністраторам, оберіть іншого провайдера в ⚙️ Налаштуваннях")
+		case api.ProviderCompatible:
+			parsed, err := url.Parse(baseURL)
+			if err != nil || !b.providerHosts[strings.ToLower(parsed.Hostname())] {
+				return nil, fmt.Errorf("сервер %s не входить до дозволених, оберіть іншого провайдера в ⚙️ Налаштуваннях", baseURL)
+			}
+			return b.compatibleProvider(chatID, baseURL, apiKey), nil
+		}
+	}
+
+	return api.NewProvider(providerName, apiKey, baseURL)
+}
+
+// switchProvider зберігає провайдера і модель та скидає кешований
+// GPT-екземпляр, щоб наступний запит пішов через новий бекенд.
+func (b *Bot) switchProvider(chatID int64, provider, baseURL, model string) {
+	if err := b.Storage.SaveProvider(chatID, provider, baseURL); err != nil {
+		b.sendMessage(chatID, "❌ Помилка збереження провайдера")
+		return
+	}
+
+	if model != "" {
+		if err := b.Storage.SaveUserSettings(chatID, model); err != nil {
+			log.Printf("Помилка збереження налаштувань моделі: %v", err)
+		}
+	}
+
+	b.chatGPTs.Delete(chatID)
+	logAction("ПРОВАЙДЕР", chatID, fmt.Sprintf("%s %s, модель %s", provider, baseURL, model))
+
+	text := fmt.Sprintf("✅ Провайдер змінено на %s", providerTitle(provider))
+	if model != "" {
+		text += fmt.Sprintf("\n🤖 Модель: %s", model)
+	} else {
+		text += "\n⚠️ Сервер не повідомив доступних моделей, поточну модель залишено без змін"
+	}
+	b.sendMessage(chatID, text)
+}
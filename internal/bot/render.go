@@ -0,0 +1,344 @@
+package bot
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Відповіді моделей приходять у CommonMark, а Telegram розуміє лише власні
+// діалекти розмітки. Перетворюємо Markdown у Telegram HTML: на відміну від
+// MarkdownV2 тут треба екранувати лише <, > і &, а незакриті * чи _ просто
+// лишаються текстом і не ламають усе повідомлення.
+
+// renderedChunk - частина відповіді, що вміщується в одне повідомлення
+type renderedChunk struct {
+	HTML  string // розмітка для ParseMode HTML; порожня - надсилати як текст
+	Plain string // вихідний текст на випадок, якщо Telegram відхилить HTML
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	bulletPattern  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	langPattern    = regexp.MustCompile(`^[\w+#.-]+$`)
+
+	htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// mdBlock - абзац або блок коду
+type mdBlock struct {
+	text  string // для коду - вміст без рядків ```
+	fence string // відкриваючий рядок ``` для блоку коду, для абзацу - ""
+}
+
+func (b mdBlock) String() string {
+	if b.fence == "" {
+		return b.text
+	}
+	return b.fence + "\n" + b.text + "\n```"
+}
+
+// renderMarkdown розбиває відповідь на частини до limit символів по межах
+// абзаців і блоків коду та перетворює кожну на Telegram HTML.
+func renderMarkdown(text string, limit int) []renderedChunk {
+	measure := func(s string) int {
+		return utf8.RuneCountInString(markdownToHTML(s))
+	}
+
+	parts := packBlocks(parseBlocks(text), limit, measure)
+	chunks := make([]renderedChunk, 0, len(parts))
+	for _, part := range parts {
+		chunks = append(chunks, renderedChunk{HTML: markdownToHTML(part), Plain: part})
+	}
+	return chunks
+}
+
+// splitText розбиває звичайний текст на частини до limit символів.
+func splitText(text string, limit int) []string {
+	return packBlocks(parseBlocks(text), limit, utf8.RuneCountInString)
+}
+
+func parseBlocks(text string) []mdBlock {
+	var (
+		blocks []mdBlock
+		lines  []string
+		fence  string
+		inCode bool
+	)
+	flush := func() {
+		if len(lines) > 0 || inCode {
+			blocks = append(blocks, mdBlock{text: strings.Join(lines, "\n"), fence: fence})
+		}
+		lines, fence = nil, ""
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inCode && strings.HasPrefix(trimmed, "```"):
+			flush()
+			inCode = false
+		case inCode:
+			lines = append(lines, line)
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			inCode, fence = true, trimmed
+		case trimmed == "":
+			flush()
+		default:
+			lines = append(lines, line)
+		}
+	}
+	// Незакритий блок коду закриваємо самі
+	flush()
+
+	return blocks
+}
+
+// packBlocks об'єднує блоки в частини, довжина яких за measure не перевищує limit.
+func packBlocks(blocks []mdBlock, limit int, measure func(string) int) []string {
+	var (
+		parts   []string
+		current string
+	)
+	for _, block := range blocks {
+		for _, piece := range splitBlock(block, limit, measure) {
+			if current == "" {
+				current = piece
+				continue
+			}
+			if measure(current+"\n\n"+piece) <= limit {
+				current += "\n\n" + piece
+				continue
+			}
+			parts = append(parts, current)
+			current = piece
+		}
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// splitBlock ділить задовгий блок по рядках, а задовгий рядок - навпіл.
+// Частини блоку коду отримують власні ```, щоб кожна лишалася кодом.
+func splitBlock(block mdBlock, limit int, measure func(string) int) []string {
+	if measure(block.String()) <= limit {
+		return []string{block.String()}
+	}
+
+	lines := strings.Split(block.text, "\n")
+	if len(lines) > 1 {
+		var (
+			pieces  []string
+			current []string
+		)
+		for _, line := range lines {
+			next := append(current, line)
+			candidate := mdBlock{text: strings.Join(next, "\n"), fence: block.fence}
+			if len(current) > 0 && measure(candidate.String()) > limit {
+				pieces = append(pieces, splitBlock(mdBlock{text: strings.Join(current, "\n"), fence: block.fence}, limit, measure)...)
+				current = []string{line}
+				continue
+			}
+			current = next
+		}
+		return append(pieces, splitBlock(mdBlock{text: strings.Join(current, "\n"), fence: block.fence}, limit, measure)...)
+	}
+
+	runes := []rune(block.text)
+	if len(runes) < 2 {
+		return []string{block.String()}
+	}
+	half := len(runes) / 2
+	return append(
+		splitBlock(mdBlock{text: string(runes[:half]), fence: block.fence}, limit, measure),
+		splitBlock(mdBlock{text: string(runes[half:]), fence: block.fence}, limit, measure)...,
+	)
+}
+
+// markdownToHTML перетворює підмножину CommonMark, яку зазвичай генерують
+// моделі, у HTML, що підтримує Telegram.
+func markdownToHTML(text string) string {
+	blocks := parseBlocks(text)
+	rendered := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.fence != "" {
+			rendered = append(rendered, renderCode(block))
+		} else {
+			rendered = append(rendered, renderParagraph(block.text))
+		}
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+func renderCode(block mdBlock) string {
+	code := htmlEscaper.Replace(block.text)
+	lang := strings.TrimSpace(strings.TrimPrefix(block.fence, "```"))
+	if lang == "" || !langPattern.MatchString(lang) {
+		return "<pre>" + code + "</pre>"
+	}
+	return `<pre><code class="language-` + lang + `">` + code + "</code></pre>"
+}
+
+func renderParagraph(text string) string {
+	var (
+		out   []string
+		quote []string
+	)
+	flushQuote := func() {
+		if len(quote) > 0 {
+			out = append(out, "<blockquote>"+strings.Join(quote, "\n")+"</blockquote>")
+			quote = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			quote = append(quote, renderInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))))
+			continue
+		}
+		flushQuote()
+
+		if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+			out = append(out, "<b>"+renderInline(m[1])+"</b>")
+		} else if m := bulletPattern.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+"• "+renderInline(m[2]))
+		} else {
+			out = append(out, renderInline(line))
+		}
+	}
+	flushQuote()
+
+	return strings.Join(out, "\n")
+}
+
+// Маркери виділення від довших до коротших
+var emphasisMarkers = []struct {
+	marker, tag string
+}{
+	{"**", "b"},
+	{"__", "b"},
+	{"~~", "s"},
+	{"*", "i"},
+	{"_", "i"},
+}
+
+func renderInline(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] == '`' {
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				out.WriteString("<code>" + htmlEscaper.Replace(s[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		}
+
+		if s[i] == '[' {
+			if text, url, n, ok := parseLink(s[i:]); ok {
+				out.WriteString(`<a href="` + attrEscaper.Replace(url) + `">` + renderInline(text) + "</a>")
+				i += n
+				continue
+			}
+		}
+
+		if marker, tag, end, ok := findEmphasis(s, i); ok {
+			out.WriteString("<" + tag + ">" + renderInline(s[i+len(marker):end]) + "</" + tag + ">")
+			i = end + len(marker)
+			continue
+		}
+
+		out.WriteString(htmlEscaper.Replace(s[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// parseLink розбирає [текст](url) на початку s. Дозволяються лише абсолютні
+// адреси - на відносні Telegram відповідає помилкою.
+func parseLink(s string) (text, url string, n int, ok bool) {
+	end := strings.Index(s, "](")
+	if end < 1 || strings.ContainsAny(s[1:end], "[]\n") {
+		return "", "", 0, false
+	}
+	closing := strings.IndexByte(s[end+2:], ')')
+	if closing < 1 {
+		return "", "", 0, false
+	}
+
+	url = s[end+2 : end+2+closing]
+	if strings.ContainsAny(url, " \n") {
+		return "", "", 0, false
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") &&
+		!strings.HasPrefix(url, "tg://") && !strings.HasPrefix(url, "mailto:") {
+		return "", "", 0, false
+	}
+	return s[1:end], url, end + 3 + closing, true
+}
+
+// findEmphasis шукає маркер виділення на позиції i і його пару. Маркер без
+// пари лишається звичайним текстом.
+func findEmphasis(s string, i int) (marker, tag string, end int, ok bool) {
+	for _, m := range emphasisMarkers {
+		if !strings.HasPrefix(s[i:], m.marker) {
+			continue
+		}
+		start := i + len(m.marker)
+		// "* пункт" або "2 * 3" - не виділення
+		if start >= len(s) || s[start] == ' ' {
+			return "", "", 0, false
+		}
+		// snake_case не є курсивом
+		if m.marker[0] == '_' && i > 0 && isWordByte(s, i-1) {
+			return "", "", 0, false
+		}
+
+		if end := findClosing(s, start, m.marker); end >= 0 {
+			return m.marker, m.tag, end, true
+		}
+		return "", "", 0, false
+	}
+	return "", "", 0, false
+}
+
+func findClosing(s string, start int, marker string) int {
+	for j := start + 1; j <= len(s)-len(marker); j++ {
+		if s[j] == '`' {
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+			}
+			continue
+		}
+		if !strings.HasPrefix(s[j:], marker) {
+			continue
+		}
+		// Подвійний маркер усередині одинарного - це вкладене виділення
+		if len(marker) == 1 && j+1 < len(s) && s[j+1] == marker[0] {
+			j++
+			continue
+		}
+		if s[j-1] == ' ' {
+			continue
+		}
+		if marker[0] == '_' && j+len(marker) < len(s) && isWordByte(s, j+len(marker)) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func isWordByte(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	if r == utf8.RuneError {
+		// Середина багатобайтового символу - це літера
+		r, _ = utf8.DecodeLastRuneInString(s[:i+1])
+	}
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
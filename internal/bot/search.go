@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/storage"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const maxSearchResults = 5
+
+// handleSearch шукає запит в історії і показує фрагменти з кнопками, щоб
+// переглянути обмін повністю або продовжити його розмову.
+func (b *Bot) handleSearch(chatID int64, query string) {
+	if query == "" {
+		b.sendMessage(chatID, "Використання: /search <запит>\nНаприклад: /search рецепт борщу")
+		return
+	}
+
+	results, err := b.Storage.SearchHistory(chatID, query, maxSearchResults)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка пошуку: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося виконати пошук")
+		return
+	}
+	logAction("ПОШУК", chatID, fmt.Sprintf("%s: знайдено %d", query, len(results)))
+
+	if len(results) == 0 {
+		b.sendMessage(chatID, fmt.Sprintf("🔍 За запитом «%s» нічого не знайдено", query))
+		return
+	}
+
+	var (
+		sb   strings.Builder
+		rows [][]tgbotapi.InlineKeyboardButton
+	)
+	fmt.Fprintf(&sb, "🔍 Результати за запитом «%s»:", html.EscapeString(query))
+	for i, r := range results {
+		fmt.Fprintf(&sb, "\n\n<b>%d.</b> <i>%s</i>\n%s", i+1, r.CreatedAt.Local().Format("02.01.2006 15:04"), highlightSnippet(r.Snippet))
+
+		row := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📖 %d", i+1), fmt.Sprintf("search_show_%d", r.ID)),
+		}
+		if r.ConversationID != 0 {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("▶️ Продовжити %d", i+1), fmt.Sprintf("search_resume_%d", r.ID)))
+		}
+		rows = append(rows, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+	}
+}
+
+// highlightSnippet екранує сніпет для HTML і виділяє збіги жирним
+func highlightSnippet(snippet string) string {
+	snippet = strings.Join(strings.Fields(snippet), " ")
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, storage.SnippetStart, "<b>")
+	return strings.ReplaceAll(snippet, storage.SnippetEnd, "</b>")
+}
+
+// handleSearchCallback обробляє кнопки результатів: search_show_<id> і
+// search_resume_<id>.
+func (b *Bot) handleSearchCallback(chatID int64, data string) {
+	action, rawID, _ := strings.Cut(data, "_")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, "Некоректний формат callback.Data")
+		return
+	}
+
+	entry, err := b.Storage.GetHistoryEntry(chatID, id)
+	if err != nil {
+		log.Printf("Помилка отримання запису історії: %v", err)
+	}
+	if entry == nil {
+		b.sendMessage(chatID, "⚠️ Запис не знайдено, можливо, історію було очищено")
+		return
+	}
+
+	switch action {
+	case "show":
+		b.sendMessage(chatID, fmt.Sprintf("🕓 %s\n\n👤 %s\n\n🤖 %s",
+			entry.CreatedAt.Local().Format(time.DateTime), entry.Message, entry.Response), true)
+	case "resume":
+		conversation, err := b.Storage.GetConversation(chatID, entry.ConversationID)
+		if err != nil {
+			log.Printf("Помилка отримання розмови: %v", err)
+		}
+		if conversation == nil {
+			b.sendMessage(chatID, "⚠️ Розмову цього запису не знайдено")
+			return
+		}
+		if conversation.Archived {
+			if err := b.Storage.ArchiveConversation(chatID, conversation.ID, false); err != nil {
+				b.sendMessage(chatID, "❌ Не вдалося повернути розмову з архіву")
+				return
+			}
+		}
+		b.openConversation(chatID, *conversation)
+	default:
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідома дія пошуку: %s", action))
+	}
+}
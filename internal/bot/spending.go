@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stateAwaitingSpendingCap = "awaiting_spending_cap"
+
+	// Частка місячного ліміту, після якої користувач отримує попередження
+	spendingWarnRatio = 0.8
+)
+
+func startOfMonth(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+
+// monthlySpend повертає оціночні витрати користувача з початку місяця.
+func (b *Bot) monthlySpend(chatID int64) (float64, error) {
+	usage, err := b.Storage.GetUsageSummary(chatID, startOfMonth(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	return usage.Cost, nil
+}
+
+// describeSpendingCap повертає рядок для меню налаштувань
+func (b *Bot) describeSpendingCap(chatID int64) string {
+	spendingCap, err := b.Storage.GetSpendingCap(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання ліміту витрат: %v", err)
+	}
+	spent, err := b.monthlySpend(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання витрат: %v", err)
+	}
+
+	if spendingCap == 0 {
+		return fmt.Sprintf("не задано (цього місяця ~$%.2f)", spent)
+	}
+	return fmt.Sprintf("$%.2f/міс (витрачено ~$%.2f)", spendingCap, spent)
+}
+
+func (b *Bot) handleSpendingCapMenu(chatID int64) {
+	b.users.Store(fmt.Sprintf("%d_state", chatID), stateAwaitingSpendingCap)
+	b.sendMessage(chatID, fmt.Sprintf(`💵 Ліміт витрат: %s
+
+Надішліть місячний ліміт у доларах США, наприклад 5 або 12.50.
+Надішліть 0, щоб вимкнути ліміт.`, b.describeSpendingCap(chatID)))
+}
+
+func (b *Bot) handleSpendingCap(chatID int64, text string) {
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "$"))
+	value = strings.ReplaceAll(value, ",", ".")
+
+	spendingCap, err := strconv.ParseFloat(value, 64)
+	if err != nil || spendingCap < 0 {
+		b.sendMessage(chatID, "⚠️ Некоректна сума. Спробуйте ще раз через ⚙️ Налаштування.")
+		return
+	}
+
+	if err := b.Storage.SaveSpendingCap(chatID, spendingCap); err != nil {
+		b.sendMessage(chatID, "❌ Помилка збереження ліміту витрат")
+		return
+	}
+
+	logAction("ЛІМІТ ВИТРАТ", chatID, fmt.Sprintf("$%.2f", spendingCap))
+	if spendingCap == 0 {
+		b.sendMessage(chatID, "✅ Ліміт витрат вимкнено")
+		return
+	}
+	b.sendMessage(chatID, fmt.Sprintf("✅ Місячний ліміт витрат: $%.2f", spendingCap))
+}
+
+// checkModelPriced відмовляє в запиті до моделі з невідомою ціною, якщо задано
+// ліміт витрат: такий запит рахувався б безкоштовним і ліміт не спрацював би.
+// Моделі Ollama працюють локально, тож для них ліміт не потрібен.
+func (b *Bot) checkModelPriced(chatID int64, model string) bool {
+	if _, ok := api.PriceFor(model); ok {
+		return true
+	}
+
+	spendingCap, err := b.Storage.GetSpendingCap(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання ліміту витрат: %v", err)
+		return true
+	}
+	if spendingCap == 0 {
+		return true
+	}
+	if provider, _, _ := b.Storage.GetProvider(chatID); provider == api.ProviderOllama {
+		return true
+	}
+
+	logAction("ЛІМІТ ВИТРАТ", chatID, fmt.Sprintf("Відмова: невідома ціна моделі %s", model))
+	b.sendMessage(chatID, fmt.Sprintf(`⛔️ Ціна моделі %s невідома, тому ліміт витрат не може врахувати цей запит.
+
+Оберіть іншу модель або вимкніть ліміт у ⚙️ Налаштування.`, model))
+	return false
+}
+
+// checkSpendingCap відмовляє, якщо запит з оціночною вартістю estimate разом
+// з витратами цього місяця перевищить ліміт користувача.
+// Повертає витрати з початку місяця і ліміт (0 - без ліміту).
+func (b *Bot) checkSpendingCap(chatID int64, estimate float64) (float64, float64, bool) {
+	spendingCap, err := b.Storage.GetSpendingCap(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання ліміту витрат: %v", err)
+		return 0, 0, true
+	}
+	if spendingCap == 0 {
+		return 0, 0, true
+	}
+
+	spent, err := b.monthlySpend(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання витрат: %v", err)
+		return 0, spendingCap, true
+	}
+
+	if spent+estimate > spendingCap {
+		logAction("ЛІМІТ ВИТРАТ", chatID, fmt.Sprintf("Відмова: витрачено $%.4f, запит ~$%.4f, ліміт $%.2f", spent, estimate, spendingCap))
+		b.sendMessage(chatID, fmt.Sprintf(`⛔️ Запит перевищить ваш місячний ліміт витрат.
+
+Витрачено цього місяця: ~$%.4f з $%.2f
+Орієнтовна вартість запиту: ~$%.4f
+
+Змініть ліміт у ⚙️ Налаштування або почніть 🔄 Новий чат, щоб зменшити контекст.`, spent, spendingCap, estimate))
+		return spent, spendingCap, false
+	}
+
+	return spent, spendingCap, true
+}
+
+// warnSpending попереджає один раз, коли витрати перетинають поріг ліміту.
+func (b *Bot) warnSpending(chatID int64, before, after, spendingCap float64) {
+	if spendingCap == 0 {
+		return
+	}
+
+	threshold := spendingCap * spendingWarnRatio
+	if before < threshold && after >= threshold {
+		logAction("ЛІМІТ ВИТРАТ", chatID, fmt.Sprintf("Попередження: ~$%.4f з $%.2f", after, spendingCap))
+		b.sendMessage(chatID, fmt.Sprintf("⚠️ Використано %.0f%% місячного ліміту витрат: ~$%.2f з $%.2f",
+			after/spendingCap*100, after, spendingCap))
+	}
+}
@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Telegram дозволяє близько одного редагування повідомлення на секунду в чаті
+	streamEditInterval = 1500 * time.Millisecond
+	streamPlaceholder  = "⏳ Генерую відповідь..."
+	streamCursor       = " ▌"
+	telegramTextLimit  = 4096
+)
+
+// streamMessage поступово оновлює одне повідомлення в міру надходження
+// фрагментів відповіді від ChatGPT.
+type streamMessage struct {
+	bot       *Bot
+	chatID    int64
+	messageID int
+	text      strings.Builder
+	lastSent  string
+	lastEdit  time.Time
+}
+
+// newStreamMessage надсилає повідомлення-заглушку, яке потім редагується.
+func (b *Bot) newStreamMessage(chatID int64) (*streamMessage, error) {
+	sent, err := b.api.Send(tgbotapi.NewMessage(chatID, streamPlaceholder))
+	if err != nil {
+		return nil, err
+	}
+
+	queue := b.getMessageQueue(chatID)
+	queue.Add(sent.MessageID)
+
+	return &streamMessage{
+		bot:       b,
+		chatID:    chatID,
+		messageID: sent.MessageID,
+		lastSent:  streamPlaceholder,
+		lastEdit:  time.Now(),
+	}, nil
+}
+
+// Append додає фрагмент і редагує повідомлення не частіше за streamEditInterval.
+func (s *streamMessage) Append(delta string) {
+	s.text.WriteString(delta)
+
+	if time.Since(s.lastEdit) < streamEditInterval {
+		return
+	}
+
+	s.edit(truncateText(s.text.String(), telegramTextLimit-utf8.RuneCountInString(streamCursor))+streamCursor, "")
+}
+
+// Finish виставляє остаточний текст з розміткою, а якщо Telegram її
+// відхиляє - звичайним текстом. Те, що не вмістилося в одне повідомлення,
+// надсилається наступними повідомленнями.
+func (s *streamMessage) Finish(text string) {
+	chunks := renderMarkdown(text, telegramTextLimit)
+	if len(chunks) == 0 {
+		s.edit(truncateText(text, telegramTextLimit), "")
+		return
+	}
+
+	if !s.edit(chunks[0].HTML, tgbotapi.ModeHTML) {
+		s.edit(truncateText(chunks[0].Plain, telegramTextLimit), "")
+	}
+	for _, chunk := range chunks[1:] {
+		s.bot.sendChunk(s.chatID, chunk)
+	}
+}
+
+// Fail замінює заглушку повідомленням про помилку.
+func (s *streamMessage) Fail(text string) {
+	s.edit(text, "")
+}
+
+func (s *streamMessage) edit(text, parseMode string) bool {
+	if text == s.lastSent && parseMode == "" {
+		return true
+	}
+
+	edit := tgbotapi.NewEditMessageText(s.chatID, s.messageID, text)
+	edit.ParseMode = parseMode
+
+	s.lastEdit = time.Now()
+	if _, err := s.bot.api.Request(edit); err != nil {
+		log.Printf("Помилка редагування повідомлення: %v", err)
+		return false
+	}
+
+	s.lastSent = text
+	return true
+}
+
+// truncateText обрізає текст до limit символів, не розриваючи UTF-8 послідовності.
+func truncateText(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit])
+}
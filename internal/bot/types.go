@@ -2,14 +2,8 @@ package bot
 
 import (
 	"sync"
-	"time"
 )
 
-type UserSettings struct {
-	RequestCount int
-	LastRequest  time.Time
-}
-
 type MessageQueue struct {
 	mu      sync.Mutex
 	ids     []int
@@ -2,15 +2,26 @@ package bot
 
 import (
 	"log"
+	"strings"
 )
 
 const (
-	maxRequestsPerDay = 3
-	bypassCode        = "1111"
-	maxStoredMessages = 100
-	logFormat         = "%-25s | %-10d | %s\n"
+	maxStoredMessages   = 100
+	logFormat           = "%-25s | %-10d | %s\n"
+	limitReachedMessage = "⚠️ Ви досягли ліміту запитів. Якщо у вас є код запрошення, активуйте його: /redeem <код>"
 )
 
 func logAction(action string, chatID int64, details string) {
 	log.Printf(logFormat, action, chatID, details)
 }
+
+// parseCommand розбиває "/command@bot arg1 arg2" на команду і аргументи.
+func parseCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil
+	}
+
+	command, _, _ := strings.Cut(fields[0], "@")
+	return command, fields[1:]
+}
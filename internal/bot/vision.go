@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Запит до зображення без підпису
+const defaultImagePrompt = "Опиши це зображення"
+
+// handlePhoto завантажує найбільший розмір фото і надсилає його моделі разом
+// з підписом.
+func (b *Bot) handlePhoto(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+
+	// Telegram надсилає кілька розмірів фото, останній - найбільший
+	photo := message.Photo[len(message.Photo)-1]
+	logAction("ФОТО", chatID, fmt.Sprintf("Отримано фото %dx%d", photo.Width, photo.Height))
+
+	if photo.FileSize > maxDownloadSize {
+		b.sendMessage(chatID, "⚠️ Файл завеликий, Telegram дозволяє ботам завантажувати до 20 МБ.")
+		return
+	}
+
+	if _, err := b.getOrCreateGPTInstance(chatID); err != nil {
+		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
+		return
+	}
+
+	// Сам запит списується з ліміту в handleGPTRequest
+	if !b.requestAllowed(chatID) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	image, err := b.downloadImage(ctx, photo.FileID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка завантаження фото: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося завантажити фото")
+		return
+	}
+
+	prompt := strings.TrimSpace(message.Caption)
+	if prompt == "" {
+		prompt = defaultImagePrompt
+	}
+
+	b.handleGPTRequest(chatID, prompt, image)
+}
+
+func (b *Bot) downloadImage(ctx context.Context, fileID string) (api.Image, error) {
+	resp, err := b.downloadFile(ctx, fileID)
+	if err != nil {
+		return api.Image{}, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize))
+	if err != nil {
+		return api.Image{}, fmt.Errorf("помилка читання файлу: %w", err)
+	}
+
+	// Telegram стискає фото в JPEG, але тип краще визначити за вмістом
+	return api.Image{MediaType: http.DetectContentType(data), Data: data}, nil
+}
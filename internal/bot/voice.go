@@ -0,0 +1,286 @@
+package bot
+
+import (
+	"GPTGRAMM/internal/api"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Telegram Bot API віддає ботам файли розміром до 20 МБ
+	maxDownloadSize = 20 * 1024 * 1024
+
+	// Найнижчий очікуваний бітрейт аудіо (16 кбіт/с), за яким оцінюється
+	// тривалість файлу, якщо Telegram її не вказав
+	minAudioBytesPerSecond = 2000
+)
+
+// handleVoice розпізнає голосове повідомлення чи аудіофайл і передає текст
+// у звичайну обробку запиту до моделі.
+func (b *Bot) handleVoice(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+
+	var (
+		fileID   string
+		fileName string
+		fileSize int
+		duration int
+	)
+	switch {
+	case message.Voice != nil:
+		fileID, fileName, fileSize = message.Voice.FileID, "voice.ogg", message.Voice.FileSize
+		duration = message.Voice.Duration
+	case message.Audio != nil:
+		fileID, fileName, fileSize = message.Audio.FileID, message.Audio.FileName, message.Audio.FileSize
+		duration = message.Audio.Duration
+		if fileName == "" {
+			fileName = "audio.mp3"
+		}
+	default:
+		return
+	}
+	logAction("ГОЛОС", chatID, fmt.Sprintf("Отримано аудіо %s (%d байт)", fileName, fileSize))
+
+	if fileSize > maxDownloadSize {
+		b.sendMessage(chatID, "⚠️ Файл завеликий, Telegram дозволяє ботам завантажувати до 20 МБ.")
+		return
+	}
+
+	gpt, err := b.getOrCreateGPTInstance(chatID)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
+		return
+	}
+
+	// Сам запит списується з ліміту в handleGPTRequest
+	if !b.requestAllowed(chatID) {
+		return
+	}
+
+	// Розпізнаний текст піде моделі, тож не платимо за розпізнавання запиту,
+	// який потім буде відхилено
+	model := b.currentModel(chatID)
+	if !b.checkModelPriced(chatID, model) {
+		return
+	}
+
+	// Тривалість аудіо Telegram може не вказати - тоді оцінюємо її за
+	// розміром файлу з запасом
+	if duration == 0 {
+		duration = fileSize / minAudioBytesPerSecond
+	}
+	cost := api.TranscriptionCost(duration)
+	estimate := cost + api.EstimateCost(model, api.Usage{PromptTokens: gpt.EstimatePromptTokensFor(model, "")})
+	spent, spendingCap, allowed := b.checkSpendingCap(chatID, estimate)
+	if !allowed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	resp, err := b.downloadFile(ctx, fileID)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка завантаження аудіо: %v", err))
+		b.sendMessage(chatID, "❌ Не вдалося завантажити аудіо")
+		return
+	}
+	defer resp.Body.Close()
+
+	transcript, err := gpt.Transcribe(ctx, fileName, resp.Body)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка розпізнавання: %v", err))
+		b.sendMessage(chatID, fmt.Sprintf("❌ Не вдалося розпізнати мовлення: %v", err))
+		return
+	}
+
+	if err := b.Storage.RecordUsage(chatID, api.WhisperModel, 0, 0, cost); err != nil {
+		log.Printf("Помилка збереження використання: %v", err)
+	}
+	b.warnSpending(chatID, spent, spent+cost, spendingCap)
+
+	logAction("РОЗПІЗНАНО", chatID, transcript)
+	b.sendMessage(chatID, "🎙 "+transcript)
+
+	b.handleGPTRequest(chatID, transcript)
+}
+
+// downloadFile відкриває файл з серверів Telegram. Тіло відповіді закриває викликач.
+func (b *Bot) downloadFile(ctx context.Context, fileID string) (*http.Response, error) {
+	url, err := b.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання посилання на файл: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення запиту: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("помилка завантаження файлу: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		log.Printf("Telegram повернув код %d при завантаженні файлу", resp.StatusCode)
+		return nil, fmt.Errorf("помилка завантаження файлу: код %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// describeVoice повертає рядок для меню налаштувань
+func describeVoice(voice string) string {
+	if voice == "" {
+		return "вимкнено"
+	}
+	return voice
+}
+
+// checkSpeechSupported повідомляє користувача, якщо його провайдер не вміє
+// синтезувати мовлення, і тоді повертає false.
+func (b *Bot) checkSpeechSupported(chatID int64) bool {
+	gpt, err := b.getOrCreateGPTInstance(chatID)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Будь ласка, спочатку надішліть свій API ключ.")
+		return false
+	}
+	if !gpt.SupportsSpeech() {
+		provider, _, _ := b.Storage.GetProvider(chatID)
+		b.sendMessage(chatID, fmt.Sprintf("🔇 %s не підтримує голосові відповіді. Оберіть іншого провайдера в ⚙️ Налаштуваннях.", providerTitle(provider)))
+		return false
+	}
+	return true
+}
+
+func (b *Bot) handleVoiceMenu(chatID int64) {
+	if !b.checkSpeechSupported(chatID) {
+		return
+	}
+
+	var (
+		rows [][]tgbotapi.InlineKeyboardButton
+		row  []tgbotapi.InlineKeyboardButton
+	)
+	for _, voice := range api.SpeechVoices {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(voice, "voice_"+voice))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔇 Вимкнути", "voice_off"),
+	))
+
+	current, err := b.Storage.GetVoice(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання голосу: %v", err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔊 Голосові відповіді: %s\n\nОберіть голос, яким бот озвучуватиме відповіді:", describeVoice(current)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Помилка надсилання повідомлення: %v", err)
+	}
+}
+
+func (b *Bot) handleVoiceCallback(chatID int64, voice string) {
+	if voice == "off" {
+		voice = ""
+	} else if !slices.Contains(api.SpeechVoices, voice) {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Невідомий голос: %s", voice))
+		return
+	}
+	if voice != "" && !b.checkSpeechSupported(chatID) {
+		return
+	}
+
+	if err := b.Storage.SaveVoice(chatID, voice); err != nil {
+		b.sendMessage(chatID, "❌ Помилка збереження налаштувань")
+		return
+	}
+
+	logAction("ГОЛОС", chatID, fmt.Sprintf("Озвучення відповідей: %s", describeVoice(voice)))
+	if voice == "" {
+		b.sendMessage(chatID, "🔇 Голосові відповіді вимкнено")
+		return
+	}
+	b.sendMessage(chatID, fmt.Sprintf("🔊 Відповіді озвучуватимуться голосом %s", voice))
+}
+
+// sendVoiceReply озвучує відповідь, якщо користувач увімкнув голосові відповіді.
+func (b *Bot) sendVoiceReply(chatID int64, gpt *api.ChatGPT, response string) {
+	voice, err := b.Storage.GetVoice(chatID)
+	if err != nil {
+		log.Printf("Помилка отримання голосу: %v", err)
+		return
+	}
+	// Голос міг лишитися увімкненим після зміни провайдера
+	if voice == "" || !gpt.SupportsSpeech() {
+		return
+	}
+
+	// Синтез оплачується за символи, тож проходить ту саму перевірку витрат
+	text := speechText(response)
+	cost := api.SpeechCost(text)
+	spent, spendingCap, allowed := b.checkSpendingCap(chatID, cost)
+	if !allowed {
+		b.sendMessage(chatID, "🔇 Відповідь не озвучено, щоб не перевищити ліміт витрат.")
+		return
+	}
+
+	action := tgbotapi.NewChatAction(chatID, tgbotapi.ChatRecordVoice)
+	if _, err := b.api.Request(action); err != nil {
+		log.Printf("Помилка надсилання дії: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	audio, err := gpt.Speak(ctx, text, voice)
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка синтезу мовлення: %v", err))
+		b.sendMessage(chatID, fmt.Sprintf("⚠️ Не вдалося озвучити відповідь: %v", err))
+		return
+	}
+
+	// Синтез оплачено, тож записуємо його до надсилання
+	if err := b.Storage.RecordUsage(chatID, api.SpeechModel, 0, 0, cost); err != nil {
+		log.Printf("Помилка збереження використання: %v", err)
+	}
+	b.warnSpending(chatID, spent, spent+cost, spendingCap)
+
+	sent, err := b.api.Send(tgbotapi.NewVoice(chatID, tgbotapi.FileBytes{Name: "answer.ogg", Bytes: audio}))
+	if err != nil {
+		logAction("ПОМИЛКА", chatID, fmt.Sprintf("Помилка надсилання голосового: %v", err))
+		return
+	}
+
+	queue := b.getMessageQueue(chatID)
+	queue.Add(sent.MessageID)
+}
+
+// speechText готує відповідь до озвучення: код вголос не читається, а текст
+// обрізається до ліміту синтезу.
+func speechText(response string) string {
+	var parts []string
+	for _, block := range parseBlocks(response) {
+		if block.fence != "" {
+			parts = append(parts, "Код наведено в повідомленні.")
+			continue
+		}
+		parts = append(parts, block.text)
+	}
+	return truncateText(strings.Join(parts, "\n\n"), api.MaxSpeechInput)
+}
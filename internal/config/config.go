@@ -1,15 +1,24 @@
 package config
 
 import (
+	"encoding/base64"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	TelegramToken string
+	TelegramToken  string
+	TokenizerFile  string         // словник BPE у форматі tiktoken; без нього токени лише оцінюються з запасом
+	PricesFile     string         // необов'язкова таблиця цін моделей (JSON)
+	EncryptionKeys map[int][]byte // майстер-ключі шифрування API ключів за версіями
+	AdminIDs       []int64        // Telegram ID адміністраторів
+	ProviderHosts  []string       // хости OpenAI-сумісних серверів, дозволені всім користувачам
 }
 
 func LoadConfig() *Config {
@@ -27,9 +36,86 @@ func LoadConfig() *Config {
 	if telegramToken == "" {
 		log.Fatal("❌ ПОМИЛКА: TELEGRAM_TOKEN не знайдено! Переконайтеся, що він є у .env або середовищі")
 	}
+	encryptionKeys, err := parseEncryptionKeys(os.Getenv("ENCRYPTION_KEYS"))
+	if err != nil {
+		log.Fatalf("❌ ПОМИЛКА: некоректний ENCRYPTION_KEYS: %v", err)
+	}
+	if len(encryptionKeys) == 0 {
+		log.Fatal("❌ ПОМИЛКА: ENCRYPTION_KEYS не знайдено! Вкажіть ключ у форматі 1:<base64 32 байти>, згенерувати: openssl rand -base64 32")
+	}
+
+	adminIDs, err := parseIDs(os.Getenv("ADMIN_IDS"))
+	if err != nil {
+		log.Fatalf("❌ ПОМИЛКА: некоректний ADMIN_IDS: %v", err)
+	}
+
 	return &Config{
-		TelegramToken: telegramToken,
+		TelegramToken:  telegramToken,
+		TokenizerFile:  os.Getenv("TOKENIZER_FILE"),
+		PricesFile:     os.Getenv("PRICES_FILE"),
+		EncryptionKeys: encryptionKeys,
+		AdminIDs:       adminIDs,
+		ProviderHosts:  parseHosts(os.Getenv("PROVIDER_HOSTS")),
+	}
+}
+
+// parseIDs розбирає список Telegram ID через кому.
+func parseIDs(value string) ([]int64, error) {
+	var ids []int64
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("некоректний ID %q: %w", item, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseHosts розбирає список хостів через кому.
+func parseHosts(value string) []string {
+	var hosts []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			hosts = append(hosts, item)
+		}
+	}
+	return hosts
+}
+
+// parseEncryptionKeys розбирає рядок виду "1:<base64>,2:<base64>".
+// Ключ з найбільшою версією використовується для шифрування нових значень.
+func parseEncryptionKeys(value string) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		versionStr, encoded, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("очікується формат <версія>:<ключ>, отримано %q", item)
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("некоректна версія ключа %q: %w", versionStr, err)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("ключ версії %d не є base64: %w", version, err)
+		}
+		keys[version] = key
 	}
+	return keys, nil
 }
 
 func findProjectRoot() (string, error) {
@@ -0,0 +1,54 @@
+package document
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk розбиває текст на фрагменти до size символів, не розриваючи абзаців,
+// якщо вони вміщуються у фрагмент. Довші абзаци ріжуться по рядках, а
+// задовгі рядки - по size символів.
+func Chunk(text string, size int) []string {
+	var (
+		chunks  []string
+		current strings.Builder
+	)
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		current.Reset()
+	}
+	add := func(piece, sep string) {
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+utf8.RuneCountInString(sep+piece) > size {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if utf8.RuneCountInString(paragraph) <= size {
+			add(paragraph, "\n\n")
+			continue
+		}
+
+		for _, line := range strings.Split(paragraph, "\n") {
+			runes := []rune(line)
+			for len(runes) > size {
+				add(string(runes[:size]), "\n")
+				runes = runes[size:]
+			}
+			add(string(runes), "\n")
+		}
+	}
+	flush()
+
+	return chunks
+}
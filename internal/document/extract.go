@@ -0,0 +1,48 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupported повертається для файлів, з яких не вдається дістати текст
+var ErrUnsupported = errors.New("непідтримуваний формат файлу")
+
+// ErrNoText повертається, якщо у файлі немає тексту (наприклад, скан PDF)
+var ErrNoText = errors.New("у файлі не знайдено тексту")
+
+// ErrTooLarge повертається, якщо стиснуті дані PDF розпаковуються в надто
+// великий обсяг
+var ErrTooLarge = errors.New("файл розпаковується в надто великий обсяг")
+
+// Extract повертає текст файлу. Підтримуються PDF і будь-які текстові
+// файли в UTF-8: звичайний текст, Markdown, код, CSV, JSON тощо.
+func Extract(name string, data []byte) (string, error) {
+	var text string
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")) || strings.EqualFold(filepath.Ext(name), ".pdf"):
+		extracted, err := extractPDF(data)
+		if err != nil {
+			return "", err
+		}
+		text = extracted
+	case isText(data):
+		text = string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	default:
+		return "", ErrUnsupported
+	}
+
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// isText вважає файл текстовим, якщо це коректний UTF-8 без нульових байтів.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) == -1
+}
@@ -0,0 +1,582 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Текст PDF дістається без сторонніх бібліотек: знаходимо всі потоки,
+// розпаковуємо FlateDecode, збираємо таблиці ToUnicode і виконуємо текстові
+// оператори (Tj, TJ, ', ") потоків вмісту сторінок. Шрифти зі своїми
+// таблицями кодування об'єднуються в одну таблицю - для звичайних документів
+// цього достатньо, а скани без текстового шару дають ErrNoText.
+
+// Імена у словнику потоку, з якими потік пропускається: фільтри, які ми не
+// розпаковуємо, а також шрифти, зображення і службові дані
+var skippedStreamNames = []string{
+	"DCTDecode", "JPXDecode", "CCITTFaxDecode", "JBIG2Decode",
+	"LZWDecode", "ASCII85Decode", "ASCIIHexDecode", "RunLengthDecode",
+	"Length1", "Length2", "Type1C", "CIDFontType0C", "OpenType",
+	"Image", "XRef", "ObjStm", "Metadata", "EmbeddedFile",
+}
+
+const (
+	// Скільки байтів можуть дати всі потоки FlateDecode одного документа:
+	// кілька кілобайтів стиснутих нулів розпаковуються в гігабайти
+	maxInflatedSize = 64 << 20
+	// Скільки кодів символів можуть містити всі таблиці ToUnicode документа
+	maxCMapEntries = 1 << 18
+	// Як далеко перед ключовим словом stream шукаємо словник потоку
+	maxStreamDictSize = 4 << 10
+)
+
+func extractPDF(data []byte) (string, error) {
+	streams, err := pdfStreams(data)
+	if err != nil {
+		return "", err
+	}
+
+	cmap := make(map[string]string)
+	var contents [][]byte
+	for _, stream := range streams {
+		if bytes.Contains(stream, []byte("beginbfchar")) || bytes.Contains(stream, []byte("beginbfrange")) {
+			parseCMap(stream, cmap)
+			continue
+		}
+		if bytes.Contains(stream, []byte("BT")) && isMostlyText(stream) {
+			contents = append(contents, stream)
+		}
+	}
+
+	var lines []string
+	for _, content := range contents {
+		for _, line := range strings.Split(contentText(content, cmap), "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+
+	// Схлопуємо послідовності порожніх рядків
+	var out []string
+	for i, line := range lines {
+		if line == "" && (i == 0 || lines[i-1] == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// pdfStreams повертає розпаковані потоки файлу, пропускаючи нетекстові.
+// Якщо разом вони перевищують maxInflatedSize, повертає ErrTooLarge.
+func pdfStreams(data []byte) ([][]byte, error) {
+	var streams [][]byte
+	budget := int64(maxInflatedSize)
+	// Словник потоку лежить між попереднім потоком і ключовим словом stream,
+	// тож кожен байт файлу переглядається не більше одного разу
+	prevEnd := 0
+	for pos := 0; ; {
+		idx := bytes.Index(data[pos:], []byte("stream"))
+		if idx < 0 {
+			break
+		}
+		keyword := pos + idx
+		pos = keyword + len("stream")
+
+		// "endstream" теж містить "stream"
+		if keyword >= 3 && string(data[keyword-3:keyword]) == "end" {
+			continue
+		}
+
+		start := pos
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		end += start
+		pos = end + len("endstream")
+
+		window := max(prevEnd, keyword-maxStreamDictSize)
+		prevEnd = pos
+		dictStart := bytes.LastIndex(data[window:keyword], []byte("obj"))
+		if dictStart < 0 {
+			dictStart = 0
+		}
+		names := dictNames(data[window+dictStart : keyword])
+		if containsAny(names, skippedStreamNames) {
+			continue
+		}
+
+		raw := bytes.TrimRight(data[start:end], "\r\n")
+		if names["FlateDecode"] {
+			reader, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			// Пошкоджений кінець потоку не заважає прочитати початок
+			decoded, _ := io.ReadAll(io.LimitReader(reader, budget+1))
+			reader.Close()
+			if int64(len(decoded)) > budget {
+				return nil, ErrTooLarge
+			}
+			budget -= int64(len(decoded))
+			raw = decoded
+		}
+		streams = append(streams, raw)
+	}
+	return streams, nil
+}
+
+// dictNames повертає всі імена (/Name) словника потоку.
+func dictNames(dict []byte) map[string]bool {
+	names := make(map[string]bool)
+	lexer := &pdfLexer{data: dict}
+	for {
+		tok, ok := lexer.next()
+		if !ok {
+			return names
+		}
+		if tok.kind == tokName {
+			names[string(tok.value)] = true
+		}
+	}
+}
+
+func containsAny(names map[string]bool, items []string) bool {
+	for _, item := range items {
+		if names[item] {
+			return true
+		}
+	}
+	return false
+}
+
+// isMostlyText відсіює двійкові потоки, в яких випадково трапилося "BT".
+func isMostlyText(stream []byte) bool {
+	binary := 0
+	for _, c := range stream {
+		if c < 0x09 || (c > 0x0d && c < 0x20) || c > 0x7e {
+			binary++
+		}
+	}
+	return binary*10 < len(stream)*3
+}
+
+// contentText виконує текстові оператори потоку вмісту сторінки.
+func contentText(stream []byte, cmap map[string]string) string {
+	var (
+		out      strings.Builder
+		operands []pdfToken
+		lastY    string
+		atLine   = true
+	)
+	lexer := &pdfLexer{data: stream}
+
+	newline := func() {
+		if !atLine {
+			out.WriteByte('\n')
+			atLine = true
+		}
+	}
+	write := func(s string) {
+		if s != "" {
+			out.WriteString(s)
+			atLine = false
+		}
+	}
+	lastString := func() string {
+		for i := len(operands) - 1; i >= 0; i-- {
+			if operands[i].kind == tokString {
+				return decodePDFString(operands[i].value, cmap)
+			}
+		}
+		return ""
+	}
+
+	for {
+		tok, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if tok.kind != tokKeyword || isNumber(tok.value) {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch string(tok.value) {
+		case "Tj":
+			write(lastString())
+		case "'", `"`:
+			newline()
+			write(lastString())
+		case "TJ":
+			for _, op := range operands {
+				switch {
+				case op.kind == tokString:
+					write(decodePDFString(op.value, cmap))
+				case op.kind == tokKeyword:
+					// Великий відступ між фрагментами - це пробіл між словами
+					if n, err := strconv.ParseFloat(string(op.value), 64); err == nil && n < -250 {
+						write(" ")
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, err := strconv.ParseFloat(string(operands[len(operands)-1].value), 64); err == nil && ty != 0 {
+					newline()
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y := string(operands[len(operands)-1].value)
+				if lastY != "" && y != lastY {
+					newline()
+				}
+				lastY = y
+			}
+		case "T*", "ET":
+			newline()
+		case "ID":
+			lexer.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+	return out.String()
+}
+
+// decodePDFString перетворює байти рядка PDF у текст за таблицею ToUnicode,
+// а без неї - як UTF-16BE з BOM або Latin-1.
+func decodePDFString(raw []byte, cmap map[string]string) string {
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		return decodeUTF16(raw[2:])
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(raw); {
+		if i+1 < len(raw) {
+			if s, ok := cmap[string(raw[i:i+2])]; ok {
+				out.WriteString(s)
+				i += 2
+				continue
+			}
+		}
+		if s, ok := cmap[string(raw[i:i+1])]; ok {
+			out.WriteString(s)
+		} else if raw[i] >= 0x20 || raw[i] == '\n' || raw[i] == '\t' {
+			out.WriteRune(rune(raw[i]))
+		}
+		i++
+	}
+	return out.String()
+}
+
+func decodeUTF16(raw []byte) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// parseCMap додає до cmap відповідності з секцій bfchar і bfrange таблиці ToUnicode.
+func parseCMap(stream []byte, cmap map[string]string) {
+	lexer := &pdfLexer{data: stream}
+	var (
+		section string
+		args    []pdfToken
+	)
+	for {
+		tok, ok := lexer.next()
+		if !ok {
+			return
+		}
+
+		if len(cmap) >= maxCMapEntries {
+			return
+		}
+
+		if tok.kind == tokKeyword {
+			switch string(tok.value) {
+			case "beginbfchar", "beginbfrange":
+				section = string(tok.value)
+			case "endbfchar", "endbfrange":
+				section = ""
+			}
+			args = args[:0]
+			continue
+		}
+		if section == "" {
+			continue
+		}
+
+		args = append(args, tok)
+		switch {
+		case section == "beginbfchar" && len(args) == 2:
+			if args[0].kind == tokString && args[1].kind == tokString {
+				cmap[string(args[0].value)] = decodeUTF16(args[1].value)
+			}
+			args = args[:0]
+		case section == "beginbfrange" && len(args) >= 3:
+			if args[2].kind == tokArrayStart {
+				if args[len(args)-1].kind != tokArrayEnd {
+					continue
+				}
+				addBFRangeArray(cmap, args[0].value, args[1].value, args[3:len(args)-1])
+			} else if args[2].kind == tokString {
+				addBFRange(cmap, args[0].value, args[1].value, args[2].value)
+			}
+			args = args[:0]
+		}
+	}
+}
+
+func addBFRange(cmap map[string]string, lo, hi, dst []byte) {
+	if len(lo) == 0 || len(lo) != len(hi) || len(dst) < 2 {
+		return
+	}
+	from, to := bytesToInt(lo), bytesToInt(hi)
+	if to < from || to-from > 0xffff {
+		return
+	}
+	to = min(to, from+maxCMapEntries-len(cmap)-1)
+
+	target := append([]byte(nil), dst...)
+	for code := from; code <= to; code++ {
+		cmap[string(intToBytes(code, len(lo)))] = decodeUTF16(target)
+
+		// Збільшуємо останню 16-бітну одиницю цільового рядка
+		n := len(target)
+		unit := uint16(target[n-2])<<8 | uint16(target[n-1])
+		unit++
+		target[n-2], target[n-1] = byte(unit>>8), byte(unit)
+	}
+}
+
+func addBFRangeArray(cmap map[string]string, lo, hi []byte, targets []pdfToken) {
+	if len(lo) == 0 || len(lo) != len(hi) {
+		return
+	}
+	from := bytesToInt(lo)
+	for i, target := range targets {
+		if len(cmap) >= maxCMapEntries {
+			return
+		}
+		if target.kind == tokString {
+			cmap[string(intToBytes(from+i, len(lo)))] = decodeUTF16(target.value)
+		}
+	}
+}
+
+func bytesToInt(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+func intToBytes(n, size int) []byte {
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
+
+func isNumber(value []byte) bool {
+	_, err := strconv.ParseFloat(string(value), 64)
+	return err == nil
+}
+
+type tokenKind int
+
+const (
+	tokKeyword tokenKind = iota // оператор, число або true/false/null
+	tokString                   // літеральний (...) або шістнадцятковий <...> рядок
+	tokName
+	tokArrayStart
+	tokArrayEnd
+)
+
+type pdfToken struct {
+	kind  tokenKind
+	value []byte
+}
+
+// pdfLexer розбиває потік вмісту PDF на лексеми
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: tokString, value: l.literal()}, true
+		case c == '<':
+			if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+				l.pos += 2
+				continue
+			}
+			return pdfToken{kind: tokString, value: l.hex()}, true
+		case c == '[':
+			l.pos++
+			return pdfToken{kind: tokArrayStart}, true
+		case c == ']':
+			l.pos++
+			return pdfToken{kind: tokArrayEnd}, true
+		case c == '/':
+			l.pos++
+			return pdfToken{kind: tokName, value: l.regular()}, true
+		case isPDFDelimiter(c):
+			// ">>", ")" без пари, "{" і "}" нас не цікавлять
+			l.pos++
+		default:
+			return pdfToken{kind: tokKeyword, value: l.regular()}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+func (l *pdfLexer) regular() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+func (l *pdfLexer) literal() []byte {
+	var out []byte
+	depth := 1
+	l.pos++
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			n := l.data[l.pos]
+			l.pos++
+			switch n {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// Перенесення рядка всередині рядка ігнорується
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if n >= '0' && n <= '7' {
+					value := int(n - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(value))
+				} else {
+					out = append(out, n)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (l *pdfLexer) hex() []byte {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; isHexDigit(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		out[i] = hexValue(digits[2*i])<<4 | hexValue(digits[2*i+1])
+	}
+	return out
+}
+
+// skipInlineImage пропускає двійкові дані вбудованого зображення до EI.
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if isPDFSpace(l.data[l.pos]) && l.data[l.pos+1] == 'E' && l.data[l.pos+2] == 'I' &&
+			(l.pos+3 == len(l.data) || isPDFSpace(l.data[l.pos+3])) {
+			l.pos += 3
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,288 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// pdfObject - потік для тестового PDF
+type pdfObject struct {
+	dict  string // вміст словника без /Length і /Filter
+	data  []byte
+	flate bool
+}
+
+// buildPDF збирає мінімальний PDF з потоків. Таблиця xref не потрібна:
+// розбір шукає потоки напряму.
+func buildPDF(t *testing.T, objects ...pdfObject) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		data := obj.data
+		dict := obj.dict
+		if obj.flate {
+			data = deflate(t, data)
+			dict += " /Filter /FlateDecode"
+		}
+		fmt.Fprintf(&buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", i+1, dict, len(data))
+		buf.Write(data)
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+	buf.WriteString("%%EOF\n")
+	return buf.Bytes()
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func content(s string) pdfObject {
+	return pdfObject{data: []byte(s)}
+}
+
+const toUnicodeCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<00> <FF>
+endcodespacerange
+1 beginbfchar
+<01> <0417>
+endbfchar
+2 beginbfrange
+<02> <04> <0430>
+<05> <06> [<0434> <0435>]
+endbfrange
+endcmap
+end end`
+
+func TestExtractPDF(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []pdfObject
+		want    string
+	}{
+		{
+			name:    "Tj",
+			objects: []pdfObject{content("BT /F1 12 Tf 72 700 Td (Hello World) Tj ET")},
+			want:    "Hello World",
+		},
+		{
+			name:    "FlateDecode",
+			objects: []pdfObject{{data: []byte("BT /F1 12 Tf 72 700 Td (Compressed text) Tj ET"), flate: true}},
+			want:    "Compressed text",
+		},
+		{
+			name:    "TJ з відступами",
+			objects: []pdfObject{content("BT [(Hel) -20 (lo) -500 (world)] TJ ET")},
+			want:    "Hello world",
+		},
+		{
+			name:    "рядки через Td",
+			objects: []pdfObject{content("BT 72 700 Td (Line one) Tj 0 -14 Td (Line two) Tj ET")},
+			want:    "Line one\nLine two",
+		},
+		{
+			name:    "екранування в рядку",
+			objects: []pdfObject{content(`BT (a \(b\) c\\d) Tj ET`)},
+			want:    `a (b) c\d`,
+		},
+		{
+			name:    "UTF-16 з BOM",
+			objects: []pdfObject{content("BT <FEFF0456043D0444043E> Tj ET")},
+			want:    "інфо",
+		},
+		{
+			name: "ToUnicode",
+			objects: []pdfObject{
+				{dict: "", data: []byte(toUnicodeCMap), flate: true},
+				{data: []byte("BT <0102030405 06> Tj ET"), flate: true},
+			},
+			want: "Забвде",
+		},
+		{
+			name: "сторінки розділяються одним порожнім рядком",
+			objects: []pdfObject{
+				content("BT (First page) Tj T* T* ET"),
+				content("BT (Second page) Tj ET"),
+			},
+			want: "First page\n\nSecond page",
+		},
+		{
+			name: "вбудоване зображення пропускається",
+			objects: []pdfObject{
+				content("BT (Before) Tj ET BI /W 1 /H 1 ID \x00(Tj)\xff EI BT (After) Tj ET"),
+			},
+			want: "Before\nAfter",
+		},
+		{
+			name: "потік зображення пропускається",
+			objects: []pdfObject{
+				{dict: "/Subtype /Image", data: []byte("BT (hidden) Tj ET")},
+				content("BT (Visible) Tj ET"),
+			},
+			want: "Visible",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract("test.pdf", buildPDF(t, tt.objects...))
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Extract = %q, очікувалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPDFMalformed(t *testing.T) {
+	var truncated bytes.Buffer
+	w := zlib.NewWriter(&truncated)
+	w.Write([]byte("BT (Readable start) Tj ET\n"))
+	w.Flush()
+	w.Write([]byte(strings.Repeat("BT (lost tail) Tj ET\n", 50)))
+	w.Close()
+	cut := truncated.Bytes()[:truncated.Len()-20]
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "без потоків",
+			data:    []byte("%PDF-1.4\ngarbage\n%%EOF"),
+			wantErr: ErrNoText,
+		},
+		{
+			name:    "немає endstream",
+			data:    []byte("%PDF-1.4\n1 0 obj\n<< /Length 10 >>\nstream\nBT (cut) Tj ET"),
+			wantErr: ErrNoText,
+		},
+		{
+			name:    "пошкоджений FlateDecode",
+			data:    buildPDF(t, pdfObject{dict: "/Filter /FlateDecode", data: []byte("BT (not zlib) Tj ET")}),
+			wantErr: ErrNoText,
+		},
+		{
+			name: "обрізаний FlateDecode",
+			data: []byte("%PDF-1.4\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\n" +
+				string(cut) + "\nendstream\nendobj\n"),
+			want: "Readable start",
+		},
+		{
+			name:    "незакритий рядок",
+			data:    buildPDF(t, content("BT (never closed Tj ET")),
+			wantErr: ErrNoText,
+		},
+		{
+			name:    "непідтримуваний фільтр",
+			data:    buildPDF(t, pdfObject{dict: "/Filter /DCTDecode", data: []byte("BT (jpeg) Tj ET")}),
+			wantErr: ErrNoText,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract("test.pdf", tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("помилка = %v, очікувалась %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("Extract = %q, очікувалось %q на початку", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPDFInflateLimit(t *testing.T) {
+	var compressed bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&compressed, zlib.BestSpeed)
+	zeros := make([]byte, 1<<20)
+	for i := 0; i <= maxInflatedSize>>20; i++ {
+		w.Write(zeros)
+	}
+	w.Close()
+
+	data := []byte("%PDF-1.4\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\n" +
+		compressed.String() + "\nendstream\nendobj\n")
+	if _, err := Extract("bomb.pdf", data); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("помилка = %v, очікувалась ErrTooLarge", err)
+	}
+}
+
+func TestPDFStreamsWithoutObjects(t *testing.T) {
+	// Без "obj" словник раніше шукався від початку файлу для кожного потоку,
+	// і розбір такого файлу тривав квадратичний час
+	const count = 1 << 20
+	data := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("stream\nendstream\n"), count)...)
+
+	streams, err := pdfStreams(data)
+	if err != nil {
+		t.Fatalf("pdfStreams: %v", err)
+	}
+	if len(streams) != count {
+		t.Errorf("потоків: %d, очікувалось %d", len(streams), count)
+	}
+}
+
+func TestParseCMapLimit(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("8 beginbfrange\n")
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&sb, "<%02X0000> <%02XFFFF> <0041>\n", i, i)
+	}
+	sb.WriteString("endbfrange\n")
+
+	cmap := make(map[string]string)
+	parseCMap([]byte(sb.String()), cmap)
+	if len(cmap) != maxCMapEntries {
+		t.Errorf("записів у таблиці: %d, очікувалось %d", len(cmap), maxCMapEntries)
+	}
+}
+
+func TestDecodePDFString(t *testing.T) {
+	cmap := map[string]string{"\x00\x41": "Ж", "\x42": "ї"}
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "Latin-1", raw: "caf\xe9", want: "café"},
+		{name: "UTF-16 BOM", raw: "\xfe\xff\x04\x16", want: "Ж"},
+		{name: "двобайтовий код", raw: "\x00\x41", want: "Ж"},
+		{name: "однобайтовий код", raw: "\x42C", want: "їC"},
+		{name: "керівні символи відкидаються", raw: "a\x01b", want: "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodePDFString([]byte(tt.raw), cmap); got != tt.want {
+				t.Errorf("decodePDFString = %q, очікувалось %q", got, tt.want)
+			}
+		})
+	}
+}
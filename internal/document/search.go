@@ -0,0 +1,79 @@
+package document
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Cosine повертає косинусну подібність двох векторів.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// TopK повертає індекси k найкращих результатів за спаданням оцінки.
+func TopK(scores []float64, k int) []int {
+	indexes := make([]int, len(scores))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return scores[indexes[i]] > scores[indexes[j]]
+	})
+
+	if len(indexes) > k {
+		indexes = indexes[:k]
+	}
+	return indexes
+}
+
+// LexicalScore оцінює фрагмент за часткою слів запиту, що в ньому трапляються.
+// Використовується, коли провайдер не вміє рахувати ембединги.
+func LexicalScore(query, chunk string) float64 {
+	terms := words(query)
+	if len(terms) == 0 {
+		return 0
+	}
+
+	present := make(map[string]bool)
+	for _, w := range words(chunk) {
+		present[w] = true
+	}
+
+	matched := 0
+	for _, term := range terms {
+		if present[term] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(terms))
+}
+
+// words повертає слова тексту в нижньому регістрі, пропускаючи короткі
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	result := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) >= 3 {
+			result = append(result, f)
+		}
+	}
+	return result
+}
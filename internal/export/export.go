@@ -0,0 +1,150 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// Формати експорту; значення збігаються з розширенням файлу
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+)
+
+// Formats - підтримувані формати у порядку показу користувачу
+var Formats = []string{FormatMarkdown, FormatJSON, FormatHTML}
+
+// Exchange - один обмін запит-відповідь
+type Exchange struct {
+	Prompt    string
+	Response  string
+	CreatedAt time.Time
+}
+
+// Writer пише історію у файл по одному обміну, не тримаючи її в пам'яті.
+// Close дописує кінець документа, але не закриває вихідний потік.
+type Writer interface {
+	WriteExchange(exchange Exchange) error
+	Close() error
+}
+
+// NewWriter створює Writer для формату format і записує заголовок документа.
+func NewWriter(format string, w io.Writer, title string) (Writer, error) {
+	out := bufio.NewWriter(w)
+
+	var writer Writer
+	switch format {
+	case FormatMarkdown:
+		writer = &markdownWriter{out: out}
+		fmt.Fprintf(out, "# %s\n", title)
+	case FormatJSON:
+		writer = &jsonWriter{out: out}
+		out.WriteString("[")
+	case FormatHTML:
+		writer = &htmlWriter{out: out}
+		fmt.Fprintf(out, htmlHeader, html.EscapeString(title), html.EscapeString(title))
+	default:
+		return nil, fmt.Errorf("невідомий формат експорту: %s", format)
+	}
+	return writer, nil
+}
+
+type markdownWriter struct {
+	out *bufio.Writer
+}
+
+func (m *markdownWriter) WriteExchange(e Exchange) error {
+	fmt.Fprintf(m.out, "\n---\n\n*%s*\n\n**Ви:**\n\n%s\n\n**Асистент:**\n\n%s\n",
+		e.CreatedAt.Format(time.DateTime), e.Prompt, e.Response)
+	return m.out.Flush()
+}
+
+func (m *markdownWriter) Close() error {
+	return m.out.Flush()
+}
+
+// jsonWriter пише масив повідомлень у форматі OpenAI Chat Completions
+type jsonWriter struct {
+	out     *bufio.Writer
+	written bool
+}
+
+type jsonMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (j *jsonWriter) WriteExchange(e Exchange) error {
+	for _, message := range []jsonMessage{
+		{Role: "user", Content: e.Prompt},
+		{Role: "assistant", Content: e.Response},
+	} {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("помилка маршалінгу повідомлення: %w", err)
+		}
+		if j.written {
+			j.out.WriteString(",")
+		}
+		j.out.WriteString("\n  ")
+		j.out.Write(data)
+		j.written = true
+	}
+	return j.out.Flush()
+}
+
+func (j *jsonWriter) Close() error {
+	j.out.WriteString("\n]\n")
+	return j.out.Flush()
+}
+
+type htmlWriter struct {
+	out *bufio.Writer
+}
+
+func (h *htmlWriter) WriteExchange(e Exchange) error {
+	fmt.Fprintf(h.out, `<div class="time">%s</div>
+<div class="msg user">%s</div>
+<div class="msg assistant">%s</div>
+`, e.CreatedAt.Format(time.DateTime), escapeMultiline(e.Prompt), escapeMultiline(e.Response))
+	return h.out.Flush()
+}
+
+func (h *htmlWriter) Close() error {
+	h.out.WriteString(htmlFooter)
+	return h.out.Flush()
+}
+
+func escapeMultiline(text string) string {
+	return strings.TrimSpace(html.EscapeString(text))
+}
+
+// Сторінка без зовнішніх ресурсів, щоб відкривалася офлайн
+const htmlHeader = `<!DOCTYPE html>
+<html lang="uk">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; max-width: 820px; margin: 0 auto; padding: 16px; background: #f4f4f5; color: #18181b; }
+h1 { font-size: 1.4em; }
+.time { color: #71717a; font-size: 0.8em; text-align: center; margin: 24px 0 8px; }
+.msg { white-space: pre-wrap; word-wrap: break-word; padding: 10px 14px; border-radius: 12px; margin: 6px 0; line-height: 1.45; }
+.user { background: #dbeafe; margin-left: 15%%; }
+.assistant { background: #ffffff; margin-right: 15%%; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+`
+
+const htmlFooter = `</body>
+</html>
+`
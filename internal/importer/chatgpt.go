@@ -0,0 +1,313 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Назва файлу з розмовами в експорті даних ChatGPT
+	exportFileName = "conversations.json"
+	// Скільки байтів conversations.json можна розпакувати з архіву
+	maxExportSize = 200 << 20
+)
+
+var (
+	// ErrNoConversations повертається, якщо в експорті немає жодної розмови з текстом
+	ErrNoConversations = errors.New("в експорті не знайдено розмов")
+	// ErrTooLarge повертається, якщо conversations.json в архіві завеликий
+	ErrTooLarge = fmt.Errorf("%s більший за %d МБ", exportFileName, maxExportSize>>20)
+)
+
+// Message - повідомлення імпортованої розмови
+type Message struct {
+	Role      string // user або assistant
+	Content   string
+	CreatedAt time.Time
+}
+
+// Exchange - запит користувача разом з відповіддю асистента
+type Exchange struct {
+	Prompt    string
+	Response  string
+	CreatedAt time.Time
+}
+
+// Conversation - розмова з експорту ChatGPT
+type Conversation struct {
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Messages  []Message
+}
+
+type exportConversation struct {
+	Title       string                `json:"title"`
+	CreateTime  float64               `json:"create_time"`
+	UpdateTime  float64               `json:"update_time"`
+	Mapping     map[string]exportNode `json:"mapping"`
+	CurrentNode string                `json:"current_node"`
+}
+
+// exportNode - вузол дерева розмови; гілки з'являються, коли користувач
+// редагує запит або перегенеровує відповідь
+type exportNode struct {
+	Message  *exportMessage `json:"message"`
+	Parent   string         `json:"parent"`
+	Children []string       `json:"children"`
+}
+
+type exportMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string `json:"content_type"`
+		Parts       []any  `json:"parts"`
+		Text        string `json:"text"`
+	} `json:"content"`
+	Metadata struct {
+		Hidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// IsChatGPTExport перевіряє, чи схожий файл на експорт ChatGPT:
+// conversations.json або zip-архів, у якому він є.
+func IsChatGPTExport(name string, data []byte) bool {
+	if strings.EqualFold(filepath.Base(name), exportFileName) {
+		return true
+	}
+	if !strings.EqualFold(filepath.Ext(name), ".zip") {
+		return false
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	return err == nil && findExportFile(archive) != nil
+}
+
+// ReadChatGPTExport розбирає conversations.json або zip-архів експорту даних
+// ChatGPT і повертає розмови від останньої оновленої.
+func ReadChatGPTExport(name string, data []byte) ([]Conversation, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if strings.EqualFold(filepath.Ext(name), ".zip") {
+		file, err := openFromZip(data)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = &sizeLimitReader{r: file, remaining: maxExportSize}
+	}
+
+	conversations, err := ParseChatGPT(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, ErrNoConversations
+	}
+
+	slices.SortStableFunc(conversations, func(a, b Conversation) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return conversations, nil
+}
+
+func openFromZip(data []byte) (io.ReadCloser, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("помилка читання архіву: %w", err)
+	}
+	file := findExportFile(archive)
+	if file == nil {
+		return nil, fmt.Errorf("в архіві немає %s", exportFileName)
+	}
+	if file.UncompressedSize64 > maxExportSize {
+		return nil, ErrTooLarge
+	}
+	return file.Open()
+}
+
+func findExportFile(archive *zip.Reader) *zip.File {
+	for _, file := range archive.File {
+		if strings.EqualFold(filepath.Base(file.Name), exportFileName) {
+			return file
+		}
+	}
+	return nil
+}
+
+// sizeLimitReader повертає ErrTooLarge, щойно прочитано більше remaining
+// байтів: розмір у заголовку архіву можна підробити.
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Перевіряємо, чи дані справді скінчилися рівно на межі
+		var probe [1]byte
+		if n, _ := io.ReadFull(l.r, probe[:]); n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// ParseChatGPT читає масив розмов по одній, не розбираючи весь файл у пам'ять
+// одразу. Розмови без текстових повідомлень пропускаються.
+func ParseChatGPT(r io.Reader) ([]Conversation, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("очікувався масив розмов %s", exportFileName)
+	}
+
+	var conversations []Conversation
+	for decoder.More() {
+		var raw exportConversation
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("помилка розбору розмови: %w", err)
+		}
+
+		conversation := Conversation{
+			Title:     strings.TrimSpace(raw.Title),
+			CreatedAt: unixTime(raw.CreateTime),
+			UpdatedAt: unixTime(raw.UpdateTime),
+			Messages:  raw.messages(),
+		}
+		if len(conversation.Messages) > 0 {
+			conversations = append(conversations, conversation)
+		}
+	}
+	return conversations, nil
+}
+
+// messages повертає повідомлення гілки, яку користувач бачив останньою:
+// від current_node вгору до кореня.
+func (c *exportConversation) messages() []Message {
+	nodeID := c.CurrentNode
+	if _, ok := c.Mapping[nodeID]; !ok {
+		nodeID = c.latestLeaf()
+	}
+
+	var messages []Message
+	visited := make(map[string]bool)
+	for nodeID != "" && !visited[nodeID] {
+		visited[nodeID] = true
+		node, ok := c.Mapping[nodeID]
+		if !ok {
+			break
+		}
+		if message, ok := node.Message.toMessage(); ok {
+			messages = append(messages, message)
+		}
+		nodeID = node.Parent
+	}
+
+	slices.Reverse(messages)
+	return messages
+}
+
+// latestLeaf шукає найсвіжіший вузол без дочірніх, якщо current_node відсутній
+func (c *exportConversation) latestLeaf() string {
+	var (
+		leaf   string
+		latest = -1.0
+	)
+	for id, node := range c.Mapping {
+		if len(node.Children) > 0 {
+			continue
+		}
+		created := 0.0
+		if node.Message != nil {
+			created = node.Message.CreateTime
+		}
+		if created > latest || (created == latest && id > leaf) {
+			leaf, latest = id, created
+		}
+	}
+	return leaf
+}
+
+func (m *exportMessage) toMessage() (Message, bool) {
+	if m == nil || m.Metadata.Hidden {
+		return Message{}, false
+	}
+	role := m.Author.Role
+	if role != "user" && role != "assistant" {
+		return Message{}, false
+	}
+
+	var content string
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+		// Зображення та інші вкладення в parts - об'єкти, беремо лише текст
+		var parts []string
+		for _, part := range m.Content.Parts {
+			if text, ok := part.(string); ok && strings.TrimSpace(text) != "" {
+				parts = append(parts, text)
+			}
+		}
+		content = strings.Join(parts, "\n")
+	case "code":
+		content = "```\n" + m.Content.Text + "\n```"
+	default:
+		return Message{}, false
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Message{}, false
+	}
+	return Message{Role: role, Content: content, CreatedAt: unixTime(m.CreateTime)}, true
+}
+
+// Exchanges об'єднує повідомлення в пари запит-відповідь. Кілька запитів
+// чи відповідей підряд склеюються в один.
+func (c Conversation) Exchanges() []Exchange {
+	var (
+		exchanges []Exchange
+		current   *Exchange
+	)
+	for _, m := range c.Messages {
+		switch {
+		case m.Role == "user" && (current == nil || current.Response != ""):
+			exchanges = append(exchanges, Exchange{Prompt: m.Content, CreatedAt: m.CreatedAt})
+			current = &exchanges[len(exchanges)-1]
+		case m.Role == "user":
+			current.Prompt += "\n\n" + m.Content
+		case current == nil:
+			// Розмова починається з відповіді асистента
+			exchanges = append(exchanges, Exchange{Response: m.Content, CreatedAt: m.CreatedAt})
+			current = &exchanges[len(exchanges)-1]
+		case current.Response == "":
+			current.Response = m.Content
+		default:
+			current.Response += "\n\n" + m.Content
+		}
+	}
+	return exchanges
+}
+
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
@@ -0,0 +1,289 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Розмова з гілкою: користувач перегенерував відповідь, і current_node
+// вказує на другу відповідь. Системне, приховане та порожнє повідомлення
+// мають бути пропущені.
+const branchedExport = `[{
+	"title": "Борщ",
+	"create_time": 1700000000,
+	"update_time": 1700000600.5,
+	"current_node": "a2",
+	"mapping": {
+		"root": {"message": null, "parent": "", "children": ["sys"]},
+		"sys": {
+			"message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": ["You are ChatGPT"]}},
+			"parent": "root", "children": ["hidden"]
+		},
+		"hidden": {
+			"message": {
+				"author": {"role": "user"},
+				"content": {"content_type": "text", "parts": ["прихований контекст"]},
+				"metadata": {"is_visually_hidden_from_conversation": true}
+			},
+			"parent": "sys", "children": ["u1"]
+		},
+		"u1": {
+			"message": {"author": {"role": "user"}, "create_time": 1700000010, "content": {"content_type": "text", "parts": ["Як зварити борщ?"]}},
+			"parent": "hidden", "children": ["a1", "a2"]
+		},
+		"a1": {
+			"message": {"author": {"role": "assistant"}, "create_time": 1700000020, "content": {"content_type": "text", "parts": ["Стара відповідь"]}},
+			"parent": "u1", "children": []
+		},
+		"a2": {
+			"message": {"author": {"role": "assistant"}, "create_time": 1700000030, "content": {"content_type": "text", "parts": ["Нова відповідь"]}},
+			"parent": "u1", "children": ["empty"]
+		},
+		"empty": {
+			"message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": [""]}},
+			"parent": "a2", "children": []
+		}
+	}
+}]`
+
+func TestParseChatGPTBranches(t *testing.T) {
+	conversations, err := ParseChatGPT(strings.NewReader(branchedExport))
+	if err != nil {
+		t.Fatalf("ParseChatGPT: %v", err)
+	}
+	if len(conversations) != 1 {
+		t.Fatalf("розмов: %d, очікувалась 1", len(conversations))
+	}
+
+	c := conversations[0]
+	if c.Title != "Борщ" {
+		t.Errorf("назва = %q", c.Title)
+	}
+	if !c.CreatedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("створено = %v", c.CreatedAt)
+	}
+	if !c.UpdatedAt.Equal(time.Unix(1700000600, 5e8)) {
+		t.Errorf("оновлено = %v", c.UpdatedAt)
+	}
+
+	want := []Message{
+		{Role: "user", Content: "Як зварити борщ?"},
+		{Role: "assistant", Content: "Нова відповідь"},
+	}
+	assertMessages(t, c.Messages, want)
+}
+
+func TestParseChatGPTMessages(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Message
+	}{
+		{
+			name: "без current_node береться найсвіжіша гілка",
+			input: `[{"mapping": {
+				"u": {"message": {"author": {"role": "user"}, "create_time": 1, "content": {"content_type": "text", "parts": ["q"]}}, "children": ["a", "b"]},
+				"a": {"message": {"author": {"role": "assistant"}, "create_time": 3, "content": {"content_type": "text", "parts": ["нова"]}}, "parent": "u"},
+				"b": {"message": {"author": {"role": "assistant"}, "create_time": 2, "content": {"content_type": "text", "parts": ["стара"]}}, "parent": "u"}
+			}}]`,
+			want: []Message{{Role: "user", Content: "q"}, {Role: "assistant", Content: "нова"}},
+		},
+		{
+			name: "код і вкладення",
+			input: `[{"current_node": "a", "mapping": {
+				"u": {"message": {"author": {"role": "user"}, "content": {"content_type": "multimodal_text", "parts": [{"asset_pointer": "file-1"}, "що на фото?"]}}, "children": ["a"]},
+				"a": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "code", "text": "print(1)"}}, "parent": "u"}
+			}}]`,
+			want: []Message{{Role: "user", Content: "що на фото?"}, {Role: "assistant", Content: "```\nprint(1)\n```"}},
+		},
+		{
+			name: "інструменти та невідомі типи пропускаються",
+			input: `[{"current_node": "a", "mapping": {
+				"u": {"message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["q"]}}, "children": ["t"]},
+				"t": {"message": {"author": {"role": "tool"}, "content": {"content_type": "text", "parts": ["результат"]}}, "parent": "u", "children": ["b"]},
+				"b": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "tether_browsing_display", "parts": []}}, "parent": "t", "children": ["a"]},
+				"a": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["відповідь"]}}, "parent": "b"}
+			}}]`,
+			want: []Message{{Role: "user", Content: "q"}, {Role: "assistant", Content: "відповідь"}},
+		},
+		{
+			name: "цикл у батьківських посиланнях",
+			input: `[{"current_node": "a", "mapping": {
+				"u": {"message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["q"]}}, "parent": "a"},
+				"a": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["r"]}}, "parent": "u"}
+			}}]`,
+			want: []Message{{Role: "user", Content: "q"}, {Role: "assistant", Content: "r"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversations, err := ParseChatGPT(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseChatGPT: %v", err)
+			}
+			if len(conversations) != 1 {
+				t.Fatalf("розмов: %d, очікувалась 1", len(conversations))
+			}
+			assertMessages(t, conversations[0].Messages, tt.want)
+		})
+	}
+}
+
+func TestParseChatGPTInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		count int
+		fails bool
+	}{
+		{name: "не масив", input: `{"title": "x"}`, fails: true},
+		{name: "обірваний JSON", input: `[{"title": "x", "mapping": {`, fails: true},
+		{name: "порожній масив", input: `[]`},
+		{name: "розмова без тексту", input: `[{"title": "x", "mapping": {}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversations, err := ParseChatGPT(strings.NewReader(tt.input))
+			if (err != nil) != tt.fails {
+				t.Fatalf("помилка = %v, очікувалась: %t", err, tt.fails)
+			}
+			if len(conversations) != tt.count {
+				t.Errorf("розмов: %d, очікувалось %d", len(conversations), tt.count)
+			}
+		})
+	}
+}
+
+func TestExchanges(t *testing.T) {
+	c := Conversation{Messages: []Message{
+		{Role: "assistant", Content: "привітання"},
+		{Role: "user", Content: "q1"},
+		{Role: "user", Content: "q1 ще"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "assistant", Content: "a1 ще"},
+		{Role: "user", Content: "q2"},
+	}}
+
+	want := []Exchange{
+		{Response: "привітання"},
+		{Prompt: "q1\n\nq1 ще", Response: "a1\n\na1 ще"},
+		{Prompt: "q2"},
+	}
+	got := c.Exchanges()
+	if len(got) != len(want) {
+		t.Fatalf("обмінів: %d, очікувалось %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Prompt != want[i].Prompt || got[i].Response != want[i].Response {
+			t.Errorf("обмін %d = %+v, очікувався %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestIsChatGPTExport(t *testing.T) {
+	export := zipArchive(t, map[string]string{"export/conversations.json": branchedExport, "chat.html": ""})
+	other := zipArchive(t, map[string]string{"report.txt": "text"})
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want bool
+	}{
+		{name: "conversations.json", file: "Conversations.JSON", want: true},
+		{name: "архів експорту", file: "export.zip", data: export, want: true},
+		{name: "інший архів", file: "photos.zip", data: other},
+		{name: "пошкоджений архів", file: "broken.zip", data: []byte("not a zip")},
+		{name: "інший файл", file: "notes.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsChatGPTExport(tt.file, tt.data); got != tt.want {
+				t.Errorf("IsChatGPTExport = %t, очікувалось %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadChatGPTExport(t *testing.T) {
+	older := strings.Replace(branchedExport, `"Борщ"`, `"Стара"`, 1)
+	older = strings.Replace(older, "1700000600.5", "1600000000", 1)
+	combined := "[" + strings.Trim(older, "[]") + "," + strings.Trim(branchedExport, "[]") + "]"
+
+	conversations, err := ReadChatGPTExport("export.zip", zipArchive(t, map[string]string{"conversations.json": combined}))
+	if err != nil {
+		t.Fatalf("ReadChatGPTExport: %v", err)
+	}
+	if len(conversations) != 2 || conversations[0].Title != "Борщ" || conversations[1].Title != "Стара" {
+		t.Fatalf("розмови мають іти від найновішої: %+v", conversations)
+	}
+
+	if _, err := ReadChatGPTExport("conversations.json", []byte(`[]`)); !errors.Is(err, ErrNoConversations) {
+		t.Errorf("помилка = %v, очікувалась ErrNoConversations", err)
+	}
+	if _, err := ReadChatGPTExport("export.zip", zipArchive(t, map[string]string{"other.json": "[]"})); err == nil {
+		t.Error("архів без conversations.json прочитано без помилки")
+	}
+}
+
+func TestSizeLimitReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		limit   int64
+		wantErr error
+	}{
+		{name: "менше межі", data: "12345", limit: 10},
+		{name: "рівно на межі", data: "1234567890", limit: 10},
+		{name: "більше межі", data: "12345678901", limit: 10, wantErr: ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &sizeLimitReader{r: strings.NewReader(tt.data), remaining: tt.limit}
+			var buf bytes.Buffer
+			_, err := buf.ReadFrom(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("помилка = %v, очікувалась %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && buf.String() != tt.data {
+				t.Errorf("прочитано %q", buf.String())
+			}
+		})
+	}
+}
+
+func assertMessages(t *testing.T, got, want []Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("повідомлень: %d (%+v), очікувалось %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i].Role != want[i].Role || got[i].Content != want[i].Content {
+			t.Errorf("повідомлення %d = %s: %q, очікувалось %s: %q", i, got[i].Role, got[i].Content, want[i].Role, want[i].Content)
+		}
+	}
+}
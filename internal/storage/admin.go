@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AdminStats - загальна статистика бота для адміністратора
+type AdminStats struct {
+	Users         int // користувачів зі збереженим ключем
+	ActiveToday   int // користувачів, що надсилали запити сьогодні
+	RequestsToday int
+	RequestsTotal int
+	Banned        int
+}
+
+// UserInfo - відомості про користувача для /admin user
+type UserInfo struct {
+	ChatID       int64
+	HasAPIKey    bool
+	RegisteredAt time.Time
+	Model        string
+	Provider     string
+	Requests     int
+	LastRequest  time.Time
+	Banned       bool
+}
+
+func (s *Storage) GetAdminStats() (AdminStats, error) {
+	var stats AdminStats
+	// День рахуємо від місцевої півночі, як і денний ліміт запитів, а
+	// created_at заповнює SQLite (CURRENT_TIMESTAMP) у UTC
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfDay := midnight.UTC().Format(time.DateTime)
+
+	err := s.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(DISTINCT chat_id) FROM chat_history WHERE created_at >= ?),
+			(SELECT COUNT(*) FROM chat_history WHERE created_at >= ?),
+			(SELECT COUNT(*) FROM chat_history),
+			(SELECT COUNT(*) FROM banned_users)
+	`, startOfDay, startOfDay).Scan(&stats.Users, &stats.ActiveToday, &stats.RequestsToday, &stats.RequestsTotal, &stats.Banned)
+	if err != nil {
+		return stats, fmt.Errorf("помилка отримання статистики: %w", err)
+	}
+	return stats, nil
+}
+
+func (s *Storage) GetUserInfo(chatID int64) (UserInfo, error) {
+	info := UserInfo{ChatID: chatID}
+
+	var registeredAt sql.NullTime
+	err := s.db.QueryRow("SELECT created_at FROM users WHERE chat_id = ?", chatID).Scan(&registeredAt)
+	if err != nil && err != sql.ErrNoRows {
+		return info, fmt.Errorf("помилка отримання користувача: %w", err)
+	}
+	info.HasAPIKey = err == nil
+	info.RegisteredAt = registeredAt.Time
+
+	err = s.db.QueryRow("SELECT model, provider FROM user_settings WHERE chat_id = ?", chatID).Scan(&info.Model, &info.Provider)
+	if err != nil && err != sql.ErrNoRows {
+		return info, fmt.Errorf("помилка отримання налаштувань: %w", err)
+	}
+
+	var lastRequest sql.NullString
+	err = s.db.QueryRow(`
+		SELECT COUNT(*), MAX(created_at) FROM chat_history WHERE chat_id = ?
+	`, chatID).Scan(&info.Requests, &lastRequest)
+	if err != nil {
+		return info, fmt.Errorf("помилка отримання історії: %w", err)
+	}
+	if lastRequest.Valid {
+		info.LastRequest, _ = time.Parse(time.DateTime, lastRequest.String)
+	}
+
+	info.Banned, err = s.IsBanned(chatID)
+	return info, err
+}
+
+func (s *Storage) BanUser(chatID, bannedBy int64) error {
+	_, err := s.db.Exec(`
+		INSERT INTO banned_users (chat_id, banned_by) VALUES (?, ?)
+		ON CONFLICT(chat_id) DO NOTHING
+	`, chatID, bannedBy)
+	return err
+}
+
+func (s *Storage) UnbanUser(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM banned_users WHERE chat_id = ?", chatID)
+	return err
+}
+
+func (s *Storage) IsBanned(chatID int64) (bool, error) {
+	var banned bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM banned_users WHERE chat_id = ?)", chatID).Scan(&banned)
+	return banned, err
+}
+
+// GetBroadcastRecipients повертає всі чати, з якими працював бот, крім заблокованих.
+func (s *Storage) GetBroadcastRecipients() ([]int64, error) {
+	rows, err := s.db.Query(`
+		SELECT chat_id FROM users
+		UNION
+		SELECT DISTINCT chat_id FROM chat_history
+		EXCEPT
+		SELECT chat_id FROM banned_users
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		chatIDs = append(chatIDs, id)
+	}
+	return chatIDs, rows.Err()
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Помилки погашення коду, які показуються користувачу
+var (
+	ErrCodeNotFound        = errors.New("код не знайдено")
+	ErrCodeExpired         = errors.New("термін дії коду минув")
+	ErrCodeExhausted       = errors.New("код вже використано максимальну кількість разів")
+	ErrCodeAlreadyRedeemed = errors.New("ви вже використали цей код")
+)
+
+// RedeemCode - код запрошення, який додає користувачу бонусні запити
+type RedeemCode struct {
+	ID        int64
+	Quota     int // бонусних запитів за одне погашення
+	MaxUses   int
+	Uses      int
+	ExpiresAt time.Time
+	CreatedBy int64
+}
+
+// Коди зберігаються лише як SHA-256: вони випадкові й достатньо довгі,
+// тож повільний хеш не потрібен
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateRedeemCode зберігає хеш нового коду.
+func (s *Storage) CreateRedeemCode(code string, quota, maxUses int, expiresAt time.Time, createdBy int64) error {
+	_, err := s.db.Exec(`
+		INSERT INTO redeem_codes (code_hash, quota, max_uses, expires_at, created_by)
+		VALUES (?, ?, ?, ?, ?)
+	`, hashCode(code), quota, maxUses, expiresAt, createdBy)
+	if err != nil {
+		return fmt.Errorf("помилка збереження коду: %w", err)
+	}
+	return nil
+}
+
+// RedeemCode погашає код для користувача: перевіряє термін дії і кількість
+// використань, нараховує бонусні запити і записує погашення в журнал.
+func (s *Storage) RedeemCode(chatID int64, code string) (int, error) {
+	s.limitMu.Lock()
+	defer s.limitMu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("помилка початку транзакції: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rc RedeemCode
+	err = tx.QueryRow(`
+		SELECT id, quota, max_uses, uses, expires_at
+		FROM redeem_codes
+		WHERE code_hash = ?
+	`, hashCode(code)).Scan(&rc.ID, &rc.Quota, &rc.MaxUses, &rc.Uses, &rc.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrCodeNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("помилка пошуку коду: %w", err)
+	}
+
+	if time.Now().After(rc.ExpiresAt) {
+		return 0, ErrCodeExpired
+	}
+	if rc.Uses >= rc.MaxUses {
+		return 0, ErrCodeExhausted
+	}
+
+	var redeemed bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM code_redemptions WHERE code_id = ? AND chat_id = ?)
+	`, rc.ID, chatID).Scan(&redeemed); err != nil {
+		return 0, err
+	}
+	if redeemed {
+		return 0, ErrCodeAlreadyRedeemed
+	}
+
+	if _, err := tx.Exec("UPDATE redeem_codes SET uses = uses + 1 WHERE id = ?", rc.ID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO code_redemptions (code_id, chat_id, quota)
+		VALUES (?, ?, ?)
+	`, rc.ID, chatID, rc.Quota); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_limits (chat_id, tier, bonus_requests)
+		VALUES (?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET bonus_requests = bonus_requests + excluded.bonus_requests
+	`, chatID, DefaultTier, rc.Quota); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return rc.Quota, nil
+}
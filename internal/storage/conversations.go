@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Message - одне повідомлення розмови у порядку надходження
+type Message struct {
+	Role      string
+	Content   string
+	CreatedAt time.Time
+}
+
+// Довжина назви розмови, що береться з першого запиту
+const maxConversationTitle = 40
+
+// Conversation - іменована розмова користувача
+type Conversation struct {
+	ID        int64
+	Title     string
+	Archived  bool
+	Exchanges int // кількість пар запит-відповідь в історії
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ActiveConversation повертає id поточної розмови користувача. Якщо обрану
+// розмову видалено чи заархівовано, активною стає остання неархівна.
+// Якщо розмов ще немає, створює першу і переносить туди записи
+// з chat_history, щоб контекст пережив оновлення бота.
+func (s *Storage) ActiveConversation(chatID int64) (int64, error) {
+	var conversationID int64
+	err := s.db.QueryRow(`
+		SELECT c.id FROM user_settings u
+		JOIN conversations c ON c.id = u.active_conversation AND c.chat_id = u.chat_id
+		WHERE u.chat_id = ? AND c.archived = 0
+	`, chatID).Scan(&conversationID)
+	if err == nil {
+		return conversationID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("помилка отримання розмови: %w", err)
+	}
+
+	err = s.db.QueryRow(`
+		SELECT id FROM conversations
+		WHERE chat_id = ? AND archived = 0
+		ORDER BY updated_at DESC, id DESC
+		LIMIT 1
+	`, chatID).Scan(&conversationID)
+	if err == nil {
+		return conversationID, s.SetActiveConversation(chatID, conversationID)
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("помилка отримання розмови: %w", err)
+	}
+
+	conversationID, err = s.NewConversation(chatID, "")
+	if err != nil {
+		return 0, err
+	}
+
+	history, err := s.GetHistory(chatID)
+	if err != nil {
+		return 0, fmt.Errorf("помилка отримання історії: %w", err)
+	}
+
+	// GetHistory повертає записи від найновішого до найстарішого
+	messages := make([]Message, 0, len(history)*2)
+	for i := len(history) - 1; i >= 0; i-- {
+		messages = append(messages,
+			Message{Role: "user", Content: history[i].Message},
+			Message{Role: "assistant", Content: history[i].Response},
+		)
+	}
+
+	if len(messages) > 0 {
+		if err := s.AppendMessages(conversationID, messages...); err != nil {
+			return 0, err
+		}
+		log.Printf("Відновлено %d повідомлень контексту для користувача %d", len(messages), chatID)
+	}
+
+	// Історія, записана до появи розмов, належить першій розмові
+	if _, err := s.db.Exec(`
+		UPDATE chat_history SET conversation_id = ?
+		WHERE chat_id = ? AND conversation_id = 0
+	`, conversationID, chatID); err != nil {
+		return 0, fmt.Errorf("помилка перенесення історії: %w", err)
+	}
+
+	return conversationID, nil
+}
+
+// NewConversation створює нову порожню розмову, яка стає активною.
+// Порожня назва заповниться першим запитом.
+func (s *Storage) NewConversation(chatID int64, title string) (int64, error) {
+	result, err := s.db.Exec("INSERT INTO conversations (chat_id, title) VALUES (?, ?)", chatID, title)
+	if err != nil {
+		return 0, fmt.Errorf("помилка створення розмови: %w", err)
+	}
+	conversationID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("помилка отримання id розмови: %w", err)
+	}
+	return conversationID, s.SetActiveConversation(chatID, conversationID)
+}
+
+// SetActiveConversation робить розмову активною.
+func (s *Storage) SetActiveConversation(chatID, conversationID int64) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_settings (chat_id, active_conversation) 
+		VALUES (?, ?) 
+		ON CONFLICT(chat_id) DO UPDATE SET 
+			active_conversation = excluded.active_conversation,
+			updated_at = CURRENT_TIMESTAMP
+	`, chatID, conversationID)
+	if err != nil {
+		return fmt.Errorf("помилка збереження активної розмови: %w", err)
+	}
+	return nil
+}
+
+// ListConversations повертає розмови користувача, починаючи з останньої
+// оновленої: звичайні або заархівовані.
+func (s *Storage) ListConversations(chatID int64, archived bool, limit int) ([]Conversation, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.title, c.archived, c.created_at, c.updated_at,
+			(SELECT COUNT(*) FROM chat_history h WHERE h.conversation_id = c.id)
+		FROM conversations c
+		WHERE c.chat_id = ? AND c.archived = ?
+		ORDER BY c.updated_at DESC, c.id DESC
+		LIMIT ?
+	`, chatID, archived, limit)
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання розмов: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []Conversation
+	for rows.Next() {
+		var c Conversation
+		if err := rows.Scan(&c.ID, &c.Title, &c.Archived, &c.CreatedAt, &c.UpdatedAt, &c.Exchanges); err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, c)
+	}
+
+	return conversations, rows.Err()
+}
+
+// GetConversation повертає розмову користувача або nil, якщо її немає.
+func (s *Storage) GetConversation(chatID, conversationID int64) (*Conversation, error) {
+	var c Conversation
+	err := s.db.QueryRow(`
+		SELECT c.id, c.title, c.archived, c.created_at, c.updated_at,
+			(SELECT COUNT(*) FROM chat_history h WHERE h.conversation_id = c.id)
+		FROM conversations c
+		WHERE c.id = ? AND c.chat_id = ?
+	`, conversationID, chatID).Scan(&c.ID, &c.Title, &c.Archived, &c.CreatedAt, &c.UpdatedAt, &c.Exchanges)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання розмови: %w", err)
+	}
+	return &c, nil
+}
+
+// RenameConversation змінює назву розмови користувача.
+func (s *Storage) RenameConversation(chatID, conversationID int64, title string) error {
+	_, err := s.db.Exec("UPDATE conversations SET title = ? WHERE id = ? AND chat_id = ?", title, conversationID, chatID)
+	if err != nil {
+		return fmt.Errorf("помилка перейменування розмови: %w", err)
+	}
+	return nil
+}
+
+// ArchiveConversation ховає розмову зі списку або повертає її з архіву.
+func (s *Storage) ArchiveConversation(chatID, conversationID int64, archived bool) error {
+	_, err := s.db.Exec("UPDATE conversations SET archived = ? WHERE id = ? AND chat_id = ?", archived, conversationID, chatID)
+	if err != nil {
+		return fmt.Errorf("помилка архівування розмови: %w", err)
+	}
+	return nil
+}
+
+// CountArchivedConversations повертає кількість заархівованих розмов.
+func (s *Storage) CountArchivedConversations(chatID int64) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM conversations WHERE chat_id = ? AND archived = 1", chatID).Scan(&count)
+	return count, err
+}
+
+// conversationTitle робить назву розмови з першого запиту
+func conversationTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if utf8.RuneCountInString(title) > maxConversationTitle {
+		title = string([]rune(title)[:maxConversationTitle-1]) + "…"
+	}
+	return title
+}
+
+// GetMessages повертає останні limit повідомлень розмови у хронологічному порядку.
+func (s *Storage) GetMessages(conversationID int64, limit int) ([]Message, error) {
+	rows, err := s.db.Query(`
+		SELECT role, content, created_at FROM (
+			SELECT id, role, content, created_at
+			FROM conversation_messages
+			WHERE conversation_id = ?
+			ORDER BY id DESC
+			LIMIT ?
+		) ORDER BY id ASC
+	`, conversationID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання повідомлень: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// AppendMessages дописує повідомлення в кінець розмови однією транзакцією.
+func (s *Storage) AppendMessages(conversationID int64, messages ...Message) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("помилка початку транзакції: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO conversation_messages (conversation_id, role, content)
+		VALUES (?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("помилка підготовки SQL-запиту: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, m := range messages {
+		if _, err := stmt.Exec(conversationID, m.Role, m.Content); err != nil {
+			return fmt.Errorf("помилка збереження повідомлення: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE conversations SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", conversationID); err != nil {
+		return fmt.Errorf("помилка оновлення розмови: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ClearMessages видаляє всі повідомлення розмови, залишаючи саму розмову.
+func (s *Storage) ClearMessages(conversationID int64) error {
+	_, err := s.db.Exec("DELETE FROM conversation_messages WHERE conversation_id = ?", conversationID)
+	return err
+}
+
+// ImportConversation створює розмову з готовими повідомленнями контексту та
+// історією обмінів і робить її активною. Час записів історії зберігається
+// з джерела, якщо він відомий.
+func (s *Storage) ImportConversation(chatID int64, title string, messages []Message, history []HistoryEntry) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("помилка початку транзакції: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO conversations (chat_id, title) VALUES (?, ?)", chatID, title)
+	if err != nil {
+		return 0, fmt.Errorf("помилка створення розмови: %w", err)
+	}
+	conversationID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("помилка отримання id розмови: %w", err)
+	}
+
+	messageStmt, err := tx.Prepare(`
+		INSERT INTO conversation_messages (conversation_id, role, content)
+		VALUES (?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("помилка підготовки SQL-запиту: %w", err)
+	}
+	defer messageStmt.Close()
+
+	for _, m := range messages {
+		if _, err := messageStmt.Exec(conversationID, m.Role, m.Content); err != nil {
+			return 0, fmt.Errorf("помилка збереження повідомлення: %w", err)
+		}
+	}
+
+	historyStmt, err := tx.Prepare(`
+		INSERT INTO chat_history (chat_id, conversation_id, message, response, created_at)
+		VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("помилка підготовки SQL-запиту: %w", err)
+	}
+	defer historyStmt.Close()
+
+	for _, entry := range history {
+		// CURRENT_TIMESTAMP у SQLite - це UTC у форматі DateTime
+		var createdAt any
+		if !entry.CreatedAt.IsZero() {
+			createdAt = entry.CreatedAt.UTC().Format(time.DateTime)
+		}
+		if _, err := historyStmt.Exec(chatID, conversationID, entry.Message, entry.Response, createdAt); err != nil {
+			return 0, fmt.Errorf("помилка збереження історії: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO user_settings (chat_id, active_conversation) 
+		VALUES (?, ?) 
+		ON CONFLICT(chat_id) DO UPDATE SET 
+			active_conversation = excluded.active_conversation,
+			updated_at = CURRENT_TIMESTAMP
+	`, chatID, conversationID); err != nil {
+		return 0, fmt.Errorf("помилка збереження активної розмови: %w", err)
+	}
+
+	return conversationID, tx.Commit()
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+)
+
+const (
+	dataKeySize = 32
+	// Версія 0 означає, що значення збережене відкритим текстом
+	plaintextKeyVersion = 0
+)
+
+// Формати зашифрованого значення (users.key_format)
+const (
+	// Ключ даних і значення зашифровано без додаткових даних (AAD)
+	keyFormatLegacy = 0
+	// Ключ даних прив'язано до chat_id і версії майстер-ключа, значення - до
+	// chat_id, тож шифротекст не можна підставити іншому користувачу
+	keyFormatBound = 1
+)
+
+// dataKeyAAD - додаткові дані для ключа даних
+func dataKeyAAD(chatID int64, version int) []byte {
+	return fmt.Appendf(nil, "api_key:%d:v%d", chatID, version)
+}
+
+// valueAAD - додаткові дані для самого значення. Версію не включаємо, бо
+// під час ротації значення не перешифровується.
+func valueAAD(chatID int64) []byte {
+	return fmt.Appendf(nil, "api_key:%d", chatID)
+}
+
+// KeyRing містить майстер-ключі шифрування за версіями. Нові записи
+// шифруються ключем з найбільшою версією, старі версії потрібні для читання
+// і ротації.
+type KeyRing struct {
+	keys    map[int][]byte
+	current int
+}
+
+func NewKeyRing(keys map[int][]byte) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("не задано жодного ключа шифрування")
+	}
+
+	ring := &KeyRing{keys: keys}
+	for version, key := range keys {
+		if version <= plaintextKeyVersion {
+			return nil, fmt.Errorf("версія ключа шифрування має бути більшою за 0: %d", version)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("ключ шифрування версії %d має бути 32 байти, отримано %d", version, len(key))
+		}
+		if version > ring.current {
+			ring.current = version
+		}
+	}
+	return ring, nil
+}
+
+// encrypt шифрує значення випадковим ключем даних, а ключ даних - поточним
+// майстер-ключем (envelope encryption). Обидва шифротексти прив'язані до
+// chatID. Повертає base64 і версію майстер-ключа.
+func (k *KeyRing) encrypt(chatID int64, plaintext string) (string, int, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", 0, fmt.Errorf("помилка генерації ключа даних: %w", err)
+	}
+
+	wrappedKey, err := seal(k.keys[k.current], dataKey, dataKeyAAD(chatID, k.current))
+	if err != nil {
+		return "", 0, err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext), valueAAD(chatID))
+	if err != nil {
+		return "", 0, err
+	}
+
+	blob := append(wrappedKey, ciphertext...)
+	return base64.StdEncoding.EncodeToString(blob), k.current, nil
+}
+
+func (k *KeyRing) decrypt(chatID int64, value string, version, format int) (string, error) {
+	if version == plaintextKeyVersion {
+		return value, nil
+	}
+
+	dataKey, ciphertext, err := k.unwrap(chatID, value, version, format)
+	if err != nil {
+		return "", err
+	}
+
+	var aad []byte
+	if format == keyFormatBound {
+		aad = valueAAD(chatID)
+	}
+	plaintext, err := open(dataKey, ciphertext, aad)
+	if err != nil {
+		return "", fmt.Errorf("помилка розшифрування значення: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// rewrap перешифровує лише ключ даних поточним майстер-ключем; сам шифротекст
+// значення не змінюється. Значення без AAD і відкритий текст шифруються
+// заново.
+func (k *KeyRing) rewrap(chatID int64, value string, version, format int) (string, int, error) {
+	if version == plaintextKeyVersion || format != keyFormatBound {
+		plaintext, err := k.decrypt(chatID, value, version, format)
+		if err != nil {
+			return "", 0, err
+		}
+		return k.encrypt(chatID, plaintext)
+	}
+
+	dataKey, ciphertext, err := k.unwrap(chatID, value, version, format)
+	if err != nil {
+		return "", 0, err
+	}
+
+	wrappedKey, err := seal(k.keys[k.current], dataKey, dataKeyAAD(chatID, k.current))
+	if err != nil {
+		return "", 0, err
+	}
+
+	blob := append(wrappedKey, ciphertext...)
+	return base64.StdEncoding.EncodeToString(blob), k.current, nil
+}
+
+func (k *KeyRing) unwrap(chatID int64, value string, version, format int) (dataKey, ciphertext []byte, err error) {
+	masterKey, ok := k.keys[version]
+	if !ok {
+		return nil, nil, fmt.Errorf("немає ключа шифрування версії %d", version)
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("пошкоджене зашифроване значення: %w", err)
+	}
+
+	wrappedLen := sealedLen(dataKeySize)
+	if len(blob) < wrappedLen {
+		return nil, nil, fmt.Errorf("пошкоджене зашифроване значення: замало даних")
+	}
+
+	var aad []byte
+	if format == keyFormatBound {
+		aad = dataKeyAAD(chatID, version)
+	}
+	dataKey, err = open(masterKey, blob[:wrappedLen], aad)
+	if err != nil {
+		return nil, nil, fmt.Errorf("помилка розшифрування ключа даних: %w", err)
+	}
+	return dataKey, blob[wrappedLen:], nil
+}
+
+// seal шифрує AES-GCM з випадковим nonce і повертає nonce||шифротекст.
+// aad автентифікується, але не шифрується.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("помилка генерації nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("замало даних")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("помилка створення шифру: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealedLen - довжина результату seal для відкритого тексту розміром n
+func sealedLen(n int) int {
+	const nonceSize, tagSize = 12, 16
+	return nonceSize + n + tagSize
+}
+
+// migrateAPIKeys шифрує ключі, збережені відкритим текстом або без AAD, і
+// перешифровує ключі даних старих версій поточним майстер-ключем.
+func (s *Storage) migrateAPIKeys() error {
+	rows, err := s.db.Query(
+		"SELECT chat_id, api_key, key_version, key_format FROM users WHERE key_version != ? OR key_format != ?",
+		s.keys.current, keyFormatBound)
+	if err != nil {
+		return fmt.Errorf("помилка читання ключів для міграції: %w", err)
+	}
+
+	type row struct {
+		chatID  int64
+		apiKey  string
+		version int
+		format  int
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.chatID, &r.apiKey, &r.version, &r.format); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("помилка початку транзакції: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range pending {
+		encrypted, version, err := s.keys.rewrap(r.chatID, r.apiKey, r.version, r.format)
+		if err != nil {
+			return fmt.Errorf("помилка шифрування ключа користувача %d: %w", r.chatID, err)
+		}
+		if _, err := tx.Exec("UPDATE users SET api_key = ?, key_version = ?, key_format = ? WHERE chat_id = ?",
+			encrypted, version, keyFormatBound, r.chatID); err != nil {
+			return fmt.Errorf("помилка оновлення ключа користувача %d: %w", r.chatID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Зашифровано API ключів: %d (версія майстер-ключа %d)", len(pending), s.keys.current)
+	return nil
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func newTestKeyRing(t *testing.T, keys map[int][]byte) *KeyRing {
+	t.Helper()
+	ring, err := NewKeyRing(keys)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	return ring
+}
+
+// newTestStorage створює сховище у тимчасовому файлі без міграції ключів
+func newTestStorage(t *testing.T, keys *KeyRing) *Storage {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := createTables(db); err != nil {
+		t.Fatalf("createTables: %v", err)
+	}
+	return &Storage{db: db, keys: keys, modelCache: make(map[int64]string)}
+}
+
+// legacyEncrypt шифрує значення так, як до прив'язки до chat_id - без AAD
+func legacyEncrypt(t *testing.T, masterKey []byte, plaintext string) string {
+	t.Helper()
+	dataKey := testKey(7)
+	wrappedKey, err := seal(masterKey, dataKey, nil)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(append(wrappedKey, ciphertext...))
+}
+
+func TestNewKeyRing(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[int][]byte
+		current int
+		wantErr bool
+	}{
+		{name: "порожній", keys: nil, wantErr: true},
+		{name: "версія 0", keys: map[int][]byte{0: testKey(1)}, wantErr: true},
+		{name: "короткий ключ", keys: map[int][]byte{1: []byte("short")}, wantErr: true},
+		{name: "одна версія", keys: map[int][]byte{1: testKey(1)}, current: 1},
+		{name: "найбільша версія", keys: map[int][]byte{1: testKey(1), 3: testKey(3), 2: testKey(2)}, current: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewKeyRing(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("помилка = %v, очікувалась: %t", err, tt.wantErr)
+			}
+			if err == nil && ring.current != tt.current {
+				t.Errorf("поточна версія = %d, очікувалась %d", ring.current, tt.current)
+			}
+		})
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	ring := newTestKeyRing(t, map[int][]byte{1: testKey(1)})
+
+	for _, plaintext := range []string{"sk-test-123", "", "ключ з юнікодом"} {
+		encrypted, version, err := ring.encrypt(42, plaintext)
+		if err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+		if version != 1 {
+			t.Errorf("версія = %d, очікувалась 1", version)
+		}
+		if plaintext != "" && bytes.Contains([]byte(encrypted), []byte(plaintext)) {
+			t.Errorf("шифротекст містить відкритий текст")
+		}
+
+		decrypted, err := ring.decrypt(42, encrypted, version, keyFormatBound)
+		if err != nil {
+			t.Fatalf("decrypt: %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("розшифровано %q, очікувалось %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestDecryptRejectsOtherChat(t *testing.T) {
+	ring := newTestKeyRing(t, map[int][]byte{1: testKey(1)})
+
+	encrypted, version, err := ring.encrypt(42, "sk-test")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if _, err := ring.decrypt(43, encrypted, version, keyFormatBound); err == nil {
+		t.Fatal("шифротекст іншого користувача розшифровано")
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	ring := newTestKeyRing(t, map[int][]byte{1: testKey(1)})
+
+	encrypted, version, err := ring.encrypt(42, "sk-test")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	blob, _ := base64.StdEncoding.DecodeString(encrypted)
+	blob[len(blob)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(blob)
+
+	tests := []struct {
+		name    string
+		value   string
+		version int
+	}{
+		{name: "змінений шифротекст", value: tampered, version: version},
+		{name: "не base64", value: "!!!", version: version},
+		{name: "замало даних", value: base64.StdEncoding.EncodeToString([]byte("short")), version: version},
+		{name: "невідома версія", value: encrypted, version: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ring.decrypt(42, tt.value, tt.version, keyFormatBound); err == nil {
+				t.Fatal("очікувалась помилка")
+			}
+		})
+	}
+}
+
+func TestDecryptPlaintextVersion(t *testing.T) {
+	ring := newTestKeyRing(t, map[int][]byte{1: testKey(1)})
+
+	decrypted, err := ring.decrypt(42, "sk-plain", plaintextKeyVersion, keyFormatLegacy)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted != "sk-plain" {
+		t.Errorf("розшифровано %q", decrypted)
+	}
+}
+
+func TestRewrapRotatesMasterKey(t *testing.T) {
+	oldRing := newTestKeyRing(t, map[int][]byte{1: testKey(1)})
+	encrypted, version, err := oldRing.encrypt(42, "sk-test")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	ring := newTestKeyRing(t, map[int][]byte{1: testKey(1), 2: testKey(2)})
+	rewrapped, newVersion, err := ring.rewrap(42, encrypted, version, keyFormatBound)
+	if err != nil {
+		t.Fatalf("rewrap: %v", err)
+	}
+	if newVersion != 2 {
+		t.Fatalf("версія після ротації = %d, очікувалась 2", newVersion)
+	}
+
+	// Старий ключ після ротації можна прибрати з конфігурації
+	newRing := newTestKeyRing(t, map[int][]byte{2: testKey(2)})
+	decrypted, err := newRing.decrypt(42, rewrapped, newVersion, keyFormatBound)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted != "sk-test" {
+		t.Errorf("розшифровано %q", decrypted)
+	}
+}
+
+func TestMigrateAPIKeys(t *testing.T) {
+	oldKey, newKey := testKey(1), testKey(2)
+	oldRing := newTestKeyRing(t, map[int][]byte{1: oldKey})
+	ring := newTestKeyRing(t, map[int][]byte{1: oldKey, 2: newKey})
+	s := newTestStorage(t, ring)
+
+	bound, _, err := oldRing.encrypt(3, "sk-old-version")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	rows := []struct {
+		chatID  int64
+		apiKey  string
+		version int
+		format  int
+		want    string
+	}{
+		{chatID: 1, apiKey: "sk-plaintext", version: plaintextKeyVersion, format: keyFormatLegacy, want: "sk-plaintext"},
+		{chatID: 2, apiKey: legacyEncrypt(t, oldKey, "sk-legacy"), version: 1, format: keyFormatLegacy, want: "sk-legacy"},
+		{chatID: 3, apiKey: bound, version: 1, format: keyFormatBound, want: "sk-old-version"},
+	}
+	for _, r := range rows {
+		if _, err := s.db.Exec("INSERT INTO users (chat_id, api_key, key_version, key_format) VALUES (?, ?, ?, ?)",
+			r.chatID, r.apiKey, r.version, r.format); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	if err := s.migrateAPIKeys(); err != nil {
+		t.Fatalf("migrateAPIKeys: %v", err)
+	}
+
+	for _, r := range rows {
+		var (
+			stored  string
+			version int
+			format  int
+		)
+		err := s.db.QueryRow("SELECT api_key, key_version, key_format FROM users WHERE chat_id = ?", r.chatID).
+			Scan(&stored, &version, &format)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		if version != 2 || format != keyFormatBound {
+			t.Errorf("chat %d: версія %d, формат %d після міграції", r.chatID, version, format)
+		}
+		if stored == r.apiKey {
+			t.Errorf("chat %d: значення не перешифровано", r.chatID)
+		}
+
+		got, err := s.GetAPIKey(r.chatID)
+		if err != nil {
+			t.Fatalf("GetAPIKey(%d): %v", r.chatID, err)
+		}
+		if got != r.want {
+			t.Errorf("GetAPIKey(%d) = %q, очікувалось %q", r.chatID, got, r.want)
+		}
+	}
+
+	// Рядок, підставлений іншому користувачу, не розшифровується
+	if _, err := s.db.Exec("UPDATE users SET api_key = (SELECT api_key FROM users WHERE chat_id = 2) WHERE chat_id = 1"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := s.GetAPIKey(1); err == nil {
+		t.Error("ключ іншого користувача розшифровано")
+	}
+}
+
+func TestSaveAPIKeyRoundTrip(t *testing.T) {
+	s := newTestStorage(t, newTestKeyRing(t, map[int][]byte{1: testKey(1)}))
+
+	if err := s.SaveAPIKey(42, "sk-first"); err != nil {
+		t.Fatalf("SaveAPIKey: %v", err)
+	}
+	if err := s.SaveAPIKey(42, "sk-second"); err != nil {
+		t.Fatalf("SaveAPIKey: %v", err)
+	}
+
+	got, err := s.GetAPIKey(42)
+	if err != nil {
+		t.Fatalf("GetAPIKey: %v", err)
+	}
+	if got != "sk-second" {
+		t.Errorf("GetAPIKey = %q, очікувалось sk-second", got)
+	}
+
+	// Після міграції нічого не змінюється
+	if err := s.migrateAPIKeys(); err != nil {
+		t.Fatalf("migrateAPIKeys: %v", err)
+	}
+	if got, _ := s.GetAPIKey(42); got != "sk-second" {
+		t.Errorf("після міграції GetAPIKey = %q", got)
+	}
+}
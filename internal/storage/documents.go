@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Document - завантажений файл, фрагменти якого використовуються для відповідей
+type Document struct {
+	ID             int64
+	Name           string
+	Size           int
+	Chunks         int
+	EmbeddingModel string // порожня, якщо фрагменти шукаються без ембедингів
+	CreatedAt      time.Time
+}
+
+// DocumentChunk - фрагмент тексту документа
+type DocumentChunk struct {
+	Index     int
+	Content   string
+	Embedding []float32
+}
+
+// SaveDocument зберігає документ з фрагментами і прив'язує його до розмови.
+func (s *Storage) SaveDocument(chatID, conversationID int64, doc Document, chunks []DocumentChunk) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("помилка початку транзакції: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO documents (chat_id, conversation_id, name, size, chunks, embedding_model)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, chatID, conversationID, doc.Name, doc.Size, len(chunks), doc.EmbeddingModel)
+	if err != nil {
+		return 0, fmt.Errorf("помилка збереження документа: %w", err)
+	}
+	documentID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("помилка отримання id документа: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO document_chunks (document_id, idx, content, embedding)
+		VALUES (?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("помилка підготовки SQL-запиту: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, chunk := range chunks {
+		if _, err := stmt.Exec(documentID, chunk.Index, chunk.Content, encodeEmbedding(chunk.Embedding)); err != nil {
+			return 0, fmt.Errorf("помилка збереження фрагмента: %w", err)
+		}
+	}
+
+	return documentID, tx.Commit()
+}
+
+// ActiveDocument повертає останній документ розмови або nil, якщо документів немає.
+func (s *Storage) ActiveDocument(conversationID int64) (*Document, error) {
+	var doc Document
+	err := s.db.QueryRow(`
+		SELECT id, name, size, chunks, embedding_model, created_at
+		FROM documents
+		WHERE conversation_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, conversationID).Scan(&doc.ID, &doc.Name, &doc.Size, &doc.Chunks, &doc.EmbeddingModel, &doc.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання документа: %w", err)
+	}
+	return &doc, nil
+}
+
+// GetDocumentChunks повертає всі фрагменти документа по порядку.
+func (s *Storage) GetDocumentChunks(documentID int64) ([]DocumentChunk, error) {
+	rows, err := s.db.Query(`
+		SELECT idx, content, embedding
+		FROM document_chunks
+		WHERE document_id = ?
+		ORDER BY idx
+	`, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання фрагментів: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []DocumentChunk
+	for rows.Next() {
+		var (
+			chunk     DocumentChunk
+			embedding []byte
+		)
+		if err := rows.Scan(&chunk.Index, &chunk.Content, &embedding); err != nil {
+			return nil, err
+		}
+		chunk.Embedding = decodeEmbedding(embedding)
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, rows.Err()
+}
+
+// deleteDocuments видаляє всі документи користувача разом з фрагментами.
+func (s *Storage) deleteDocuments(chatID int64) error {
+	if _, err := s.db.Exec(`
+		DELETE FROM document_chunks
+		WHERE document_id IN (SELECT id FROM documents WHERE chat_id = ?)
+	`, chatID); err != nil {
+		return fmt.Errorf("помилка видалення фрагментів документів: %w", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM documents WHERE chat_id = ?", chatID); err != nil {
+		return fmt.Errorf("помилка видалення документів: %w", err)
+	}
+	return nil
+}
+
+// Ембединги зберігаються як float32 little-endian підряд
+func encodeEmbedding(vector []float32) []byte {
+	if len(vector) == 0 {
+		return nil
+	}
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+func decodeEmbedding(data []byte) []float32 {
+	if len(data) == 0 {
+		return nil
+	}
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
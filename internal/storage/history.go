@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Скільки записів історії читати за один запит під час перебору
+const historyPageSize = 200
+
+// HistoryEntry - один обмін запит-відповідь з chat_history
+type HistoryEntry struct {
+	ID             int64
+	ConversationID int64
+	Message        string
+	Response       string
+	CreatedAt      time.Time
+}
+
+// HistoryPage повертає до limit записів історії з id більшим за afterID у
+// хронологічному порядку. conversationID 0 означає всю історію користувача.
+func (s *Storage) HistoryPage(chatID, conversationID, afterID int64, limit int) ([]HistoryEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, conversation_id, message, response, created_at
+		FROM chat_history
+		WHERE chat_id = ? AND (? = 0 OR conversation_id = ?) AND id > ?
+		ORDER BY id
+		LIMIT ?
+	`, chatID, conversationID, conversationID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання історії: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		if err := rows.Scan(&e.ID, &e.ConversationID, &e.Message, &e.Response, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// EachHistoryEntry викликає fn для кожного запису історії по порядку, читаючи
+// історію сторінками, щоб не тримати її всю в пам'яті.
+func (s *Storage) EachHistoryEntry(chatID, conversationID int64, fn func(HistoryEntry) error) error {
+	var afterID int64
+	for {
+		page, err := s.HistoryPage(chatID, conversationID, afterID, historyPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range page {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < historyPageSize {
+			return nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// CountHistory повертає кількість записів історії розмови або, якщо
+// conversationID 0, усієї історії користувача.
+func (s *Storage) CountHistory(chatID, conversationID int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM chat_history
+		WHERE chat_id = ? AND (? = 0 OR conversation_id = ?)
+	`, chatID, conversationID, conversationID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("помилка підрахунку історії: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,55 @@
+package storage
+
+import "fmt"
+
+// GeneratedImage - запис про згенероване зображення
+type GeneratedImage struct {
+	Prompt        string
+	RevisedPrompt string
+	Model         string
+	Size          string
+	Quality       string
+	FileID        string // file_id Telegram для повторного надсилання
+	Cost          float64
+}
+
+// SaveGeneratedImage записує згенероване зображення і додає його вартість
+// до використання користувача. Повертає id запису.
+func (s *Storage) SaveGeneratedImage(chatID int64, image GeneratedImage) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("помилка початку транзакції: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO generated_images (chat_id, prompt, revised_prompt, model, size, quality, file_id, cost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, chatID, image.Prompt, image.RevisedPrompt, image.Model, image.Size, image.Quality, image.FileID, image.Cost)
+	if err != nil {
+		return 0, fmt.Errorf("помилка збереження зображення: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("помилка отримання id зображення: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO usage_log (chat_id, model, prompt_tokens, completion_tokens, cost)
+		VALUES (?, ?, 0, 0, ?)
+	`, chatID, image.Model, image.Cost); err != nil {
+		return 0, fmt.Errorf("помилка збереження використання: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("помилка збереження зображення: %w", err)
+	}
+	return id, nil
+}
+
+// SetGeneratedImageFileID зберігає file_id зображення після надсилання в Telegram.
+func (s *Storage) SetGeneratedImageFileID(id int64, fileID string) error {
+	if _, err := s.db.Exec(`UPDATE generated_images SET file_id = ? WHERE id = ?`, fileID, id); err != nil {
+		return fmt.Errorf("помилка збереження file_id зображення: %w", err)
+	}
+	return nil
+}
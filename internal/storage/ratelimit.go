@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Політики обмеження запитів
+const (
+	// PolicyDaily - фіксована кількість запитів на календарний день
+	PolicyDaily = "daily"
+	// PolicyTokenBucket - відро на limit запитів, що поступово наповнюється
+	// зі швидкістю limit запитів за добу
+	PolicyTokenBucket = "token_bucket"
+
+	DefaultTier = "free"
+)
+
+// Tier - рівень доступу з власною політикою лімітів. Limit = 0 означає без обмежень.
+type Tier struct {
+	Name   string
+	Policy string
+	Limit  int
+}
+
+// LimitStatus - поточний стан ліміту користувача
+type LimitStatus struct {
+	Tier      Tier
+	Used      int       // використано запитів у поточному вікні
+	Remaining int       // скільки запитів ще доступно
+	Bonus     int       // бонусні запити з кодів, що витрачаються після ліміту
+	Blocked   bool      // адміністратор задав персональний ліміт 0
+	ResetAt   time.Time // коли ліміт відновиться (наповниться хоча б один запит)
+}
+
+var defaultTiers = []Tier{
+	{Name: DefaultTier, Policy: PolicyDaily, Limit: 3},
+	{Name: "basic", Policy: PolicyTokenBucket, Limit: 30},
+	{Name: "unlimited", Policy: PolicyDaily, Limit: 0},
+}
+
+func seedTiers(db *sql.DB) error {
+	for _, t := range defaultTiers {
+		if _, err := db.Exec(`
+			INSERT OR IGNORE INTO rate_limit_tiers (name, policy, request_limit)
+			VALUES (?, ?, ?)
+		`, t.Name, t.Policy, t.Limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveTier створює або змінює рівень доступу.
+func (s *Storage) SaveTier(t Tier) error {
+	if t.Policy != PolicyDaily && t.Policy != PolicyTokenBucket {
+		return fmt.Errorf("невідома політика ліміту: %s", t.Policy)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO rate_limit_tiers (name, policy, request_limit)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			policy = excluded.policy,
+			request_limit = excluded.request_limit
+	`, t.Name, t.Policy, t.Limit)
+	return err
+}
+
+func (s *Storage) GetTiers() ([]Tier, error) {
+	rows, err := s.db.Query("SELECT name, policy, request_limit FROM rate_limit_tiers ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tiers []Tier
+	for rows.Next() {
+		var t Tier
+		if err := rows.Scan(&t.Name, &t.Policy, &t.Limit); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// SetUserTier призначає користувачу рівень доступу і скидає його лічильник.
+func (s *Storage) SetUserTier(chatID int64, tier string) error {
+	s.limitMu.Lock()
+	defer s.limitMu.Unlock()
+
+	var exists bool
+	if err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM rate_limit_tiers WHERE name = ?)", tier).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("рівень доступу %q не існує", tier)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO user_limits (chat_id, tier)
+		VALUES (?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET tier = excluded.tier
+	`, chatID, tier); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM rate_limit_state WHERE chat_id = ?", chatID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetCustomLimit задає користувачу персональний ліміт запитів замість ліміту
+// його рівня; limit = 0 забороняє запити.
+func (s *Storage) SetCustomLimit(chatID int64, limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("ліміт запитів не може бути від'ємним: %d", limit)
+	}
+	return s.setCustomLimit(chatID, limit, false)
+}
+
+// SetUnlimited знімає з користувача обмеження кількості запитів.
+func (s *Storage) SetUnlimited(chatID int64) error {
+	return s.setCustomLimit(chatID, nil, true)
+}
+
+// ResetCustomLimit повертає користувачу ліміт його рівня.
+func (s *Storage) ResetCustomLimit(chatID int64) error {
+	return s.setCustomLimit(chatID, nil, false)
+}
+
+func (s *Storage) setCustomLimit(chatID int64, customLimit any, unlimited bool) error {
+	s.limitMu.Lock()
+	defer s.limitMu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT INTO user_limits (chat_id, tier, custom_limit, unlimited)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET
+			custom_limit = excluded.custom_limit,
+			unlimited = excluded.unlimited
+	`, chatID, DefaultTier, customLimit, unlimited)
+	return err
+}
+
+// ConsumeRequest атомарно перевіряє ліміт і, якщо запит дозволено, списує його.
+func (s *Storage) ConsumeRequest(chatID int64) (bool, LimitStatus, error) {
+	return s.updateLimit(chatID, true)
+}
+
+// CanRequest перевіряє, чи дозволено запит, нічого не списуючи.
+func (s *Storage) CanRequest(chatID int64) (bool, LimitStatus, error) {
+	return s.updateLimit(chatID, false)
+}
+
+// GetLimitStatus повертає стан ліміту, нічого не списуючи.
+func (s *Storage) GetLimitStatus(chatID int64) (LimitStatus, error) {
+	_, status, err := s.updateLimit(chatID, false)
+	return status, err
+}
+
+// ResetLimit обнуляє лічильник користувача у поточному вікні.
+func (s *Storage) ResetLimit(chatID int64) error {
+	s.limitMu.Lock()
+	defer s.limitMu.Unlock()
+
+	_, err := s.db.Exec("DELETE FROM rate_limit_state WHERE chat_id = ?", chatID)
+	return err
+}
+
+// updateLimit читає і оновлює стан ліміту в одній транзакції. Пул воркерів
+// обробляє повідомлення одного користувача паралельно, тому перевірка і
+// списання виконуються під м'ютексом, а не двома незалежними запитами.
+func (s *Storage) updateLimit(chatID int64, consume bool) (bool, LimitStatus, error) {
+	s.limitMu.Lock()
+	defer s.limitMu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, LimitStatus{}, fmt.Errorf("помилка початку транзакції: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		tier        Tier
+		customLimit sql.NullInt64
+		unlimited   bool
+		bonus       int
+	)
+	err = tx.QueryRow(`
+		SELECT t.name, t.policy, t.request_limit,
+			(SELECT custom_limit FROM user_limits WHERE chat_id = ?),
+			COALESCE((SELECT unlimited FROM user_limits WHERE chat_id = ?), 0),
+			COALESCE((SELECT bonus_requests FROM user_limits WHERE chat_id = ?), 0)
+		FROM rate_limit_tiers t
+		WHERE t.name = COALESCE((SELECT tier FROM user_limits WHERE chat_id = ?), ?)
+	`, chatID, chatID, chatID, chatID, DefaultTier).Scan(&tier.Name, &tier.Policy, &tier.Limit, &customLimit, &unlimited, &bonus)
+	if err != nil {
+		return false, LimitStatus{}, fmt.Errorf("помилка отримання рівня доступу: %w", err)
+	}
+
+	// Персональний ліміт, заданий адміністратором, має пріоритет над лімітом рівня
+	blocked := false
+	switch {
+	case unlimited:
+		tier.Limit = 0
+	case customLimit.Valid && customLimit.Int64 == 0:
+		blocked = true
+	case customLimit.Valid:
+		tier.Limit = int(customLimit.Int64)
+	}
+
+	var (
+		count     int
+		tokens    float64
+		updatedAt time.Time
+		hasState  = true
+	)
+	err = tx.QueryRow(`
+		SELECT request_count, tokens, updated_at FROM rate_limit_state WHERE chat_id = ?
+	`, chatID).Scan(&count, &tokens, &updatedAt)
+	if err == sql.ErrNoRows {
+		hasState = false
+	} else if err != nil {
+		return false, LimitStatus{}, fmt.Errorf("помилка отримання стану ліміту: %w", err)
+	}
+
+	now := time.Now()
+	status := LimitStatus{Tier: tier}
+	allowed := true
+
+	switch {
+	case blocked:
+		allowed = false
+		status.Blocked = true
+		status.Used = count
+
+	case tier.Limit == 0:
+		if consume {
+			count++
+		}
+		status.Used = count
+		status.Remaining = math.MaxInt32
+
+	case tier.Policy == PolicyTokenBucket:
+		capacity := float64(tier.Limit)
+		ratePerSecond := capacity / (24 * time.Hour).Seconds()
+		if !hasState {
+			tokens = capacity
+		} else {
+			tokens = math.Min(capacity, tokens+now.Sub(updatedAt).Seconds()*ratePerSecond)
+		}
+
+		if tokens < 1 {
+			allowed = false
+		} else if consume {
+			tokens--
+			count++
+		}
+
+		status.Used = tier.Limit - int(tokens)
+		status.Remaining = int(tokens)
+		status.ResetAt = now.Add(time.Duration((1 - (tokens - math.Floor(tokens))) / ratePerSecond * float64(time.Second)))
+
+	default: // PolicyDaily
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if hasState && updatedAt.In(now.Location()).Before(midnight) {
+			count = 0
+		}
+
+		if count >= tier.Limit {
+			allowed = false
+		} else if consume {
+			count++
+		}
+
+		status.Used = count
+		status.Remaining = max(0, tier.Limit-count)
+		status.ResetAt = midnight.AddDate(0, 0, 1)
+	}
+
+	// Коли основний ліміт вичерпано, витрачаємо бонусні запити. Заборону
+	// запитів адміністратором бонуси не обходять
+	usedBonus := false
+	if !allowed && !blocked && bonus > 0 {
+		allowed = true
+		if consume {
+			usedBonus = true
+			bonus--
+		}
+	}
+	status.Bonus = bonus
+
+	if usedBonus {
+		if _, err := tx.Exec(`
+			UPDATE user_limits SET bonus_requests = bonus_requests - 1 WHERE chat_id = ?
+		`, chatID); err != nil {
+			return false, LimitStatus{}, fmt.Errorf("помилка списання бонусного запиту: %w", err)
+		}
+	} else if consume && allowed {
+		if _, err := tx.Exec(`
+			INSERT INTO rate_limit_state (chat_id, request_count, tokens, updated_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(chat_id) DO UPDATE SET
+				request_count = excluded.request_count,
+				tokens = excluded.tokens,
+				updated_at = excluded.updated_at
+		`, chatID, count, tokens, now); err != nil {
+			return false, LimitStatus{}, fmt.Errorf("помилка оновлення ліміту: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, LimitStatus{}, err
+	}
+	return allowed, status, nil
+}
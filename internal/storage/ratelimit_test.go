@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestCustomLimit(t *testing.T) {
+	s := newTestStorage(t, nil)
+	const chatID = 42
+
+	consume := func(want bool) {
+		t.Helper()
+		allowed, _, err := s.ConsumeRequest(chatID)
+		if err != nil {
+			t.Fatalf("ConsumeRequest: %v", err)
+		}
+		if allowed != want {
+			t.Fatalf("ConsumeRequest = %t, очікувалось %t", allowed, want)
+		}
+	}
+
+	// Ліміт 0 забороняє запити, навіть бонусні
+	if err := s.SetCustomLimit(chatID, 0); err != nil {
+		t.Fatalf("SetCustomLimit: %v", err)
+	}
+	if _, err := s.db.Exec("INSERT INTO user_limits (chat_id, tier) VALUES (?, ?) ON CONFLICT(chat_id) DO UPDATE SET bonus_requests = 5", chatID, DefaultTier); err != nil {
+		t.Fatalf("bonus: %v", err)
+	}
+	consume(false)
+	status, err := s.GetLimitStatus(chatID)
+	if err != nil {
+		t.Fatalf("GetLimitStatus: %v", err)
+	}
+	if !status.Blocked {
+		t.Error("ліміт 0 не позначено як заборону")
+	}
+
+	if err := s.SetCustomLimit(chatID, 1); err != nil {
+		t.Fatalf("SetCustomLimit: %v", err)
+	}
+	consume(true)
+	consume(true) // бонусний запит
+
+	if err := s.SetUnlimited(chatID); err != nil {
+		t.Fatalf("SetUnlimited: %v", err)
+	}
+	for range 10 {
+		consume(true)
+	}
+
+	if err := s.ResetCustomLimit(chatID); err != nil {
+		t.Fatalf("ResetCustomLimit: %v", err)
+	}
+	status, err = s.GetLimitStatus(chatID)
+	if err != nil {
+		t.Fatalf("GetLimitStatus: %v", err)
+	}
+	if status.Tier.Limit != 3 || status.Blocked {
+		t.Errorf("після скидання ліміт %d, заборона %t; очікувався ліміт рівня 3", status.Tier.Limit, status.Blocked)
+	}
+
+	if err := s.SetCustomLimit(chatID, -1); err == nil {
+		t.Error("від'ємний ліміт прийнято")
+	}
+}
+
+func TestMigrateLegacyUnlimited(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// До появи колонки unlimited ліміт 0 означав "без обмежень"
+	if _, err := db.Exec(`CREATE TABLE user_limits (
+		chat_id INTEGER PRIMARY KEY,
+		tier TEXT NOT NULL,
+		bonus_requests INTEGER NOT NULL DEFAULT 0,
+		custom_limit INTEGER
+	)`); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO user_limits (chat_id, tier, custom_limit) VALUES (1, 'free', 0), (2, 'free', 7)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := createTables(db); err != nil {
+		t.Fatalf("createTables: %v", err)
+	}
+	s := &Storage{db: db, modelCache: make(map[int64]string)}
+
+	if status, _ := s.GetLimitStatus(1); status.Tier.Limit != 0 || status.Blocked {
+		t.Errorf("старий ліміт 0 після міграції: ліміт %d, заборона %t", status.Tier.Limit, status.Blocked)
+	}
+	if status, _ := s.GetLimitStatus(2); status.Tier.Limit != 7 {
+		t.Errorf("старий ліміт 7 після міграції: %d", status.Tier.Limit)
+	}
+
+	// Повторний запуск не чіпає нові заборони
+	if err := s.SetCustomLimit(1, 0); err != nil {
+		t.Fatalf("SetCustomLimit: %v", err)
+	}
+	if err := createTables(db); err != nil {
+		t.Fatalf("createTables: %v", err)
+	}
+	if status, _ := s.GetLimitStatus(1); !status.Blocked {
+		t.Error("повторна міграція зняла заборону")
+	}
+}
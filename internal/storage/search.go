@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Маркери початку і кінця збігу в сніпеті; викликач замінює їх на розмітку
+const (
+	SnippetStart = "\x02"
+	SnippetEnd   = "\x03"
+)
+
+// SearchResult - знайдений обмін з фрагментом навколо збігу
+type SearchResult struct {
+	HistoryEntry
+	Snippet string
+}
+
+// createSearchIndex створює повнотекстовий індекс FTS5 по chat_history.
+// Індекс зберігає лише токени, а текст бере з chat_history; тригери
+// підтримують його в актуальному стані.
+func createSearchIndex(db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'chat_history_fts')
+	`).Scan(&exists); err != nil {
+		return err
+	}
+
+	queries := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS chat_history_fts USING fts5(
+			message, response,
+			content = 'chat_history', content_rowid = 'id',
+			tokenize = 'unicode61 remove_diacritics 2'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS chat_history_fts_insert AFTER INSERT ON chat_history BEGIN
+			INSERT INTO chat_history_fts (rowid, message, response) VALUES (new.id, new.message, new.response);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS chat_history_fts_delete AFTER DELETE ON chat_history BEGIN
+			INSERT INTO chat_history_fts (chat_history_fts, rowid, message, response)
+			VALUES ('delete', old.id, old.message, old.response);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS chat_history_fts_update AFTER UPDATE OF message, response ON chat_history BEGIN
+			INSERT INTO chat_history_fts (chat_history_fts, rowid, message, response)
+			VALUES ('delete', old.id, old.message, old.response);
+			INSERT INTO chat_history_fts (rowid, message, response) VALUES (new.id, new.message, new.response);
+		END`,
+	}
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	// Історію, накопичену до появи індексу, індексуємо один раз
+	if !exists {
+		if _, err := db.Exec(`INSERT INTO chat_history_fts (chat_history_fts) VALUES ('rebuild')`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchHistory шукає обміни користувача за словами запиту, від найбільш
+// релевантних. Кожне слово шукається як префікс, тож "ключ" знайде і "ключі".
+func (s *Storage) SearchHistory(chatID int64, query string, limit int) ([]SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT h.id, h.conversation_id, COALESCE(h.message, ''), COALESCE(h.response, ''), h.created_at,
+			snippet(chat_history_fts, -1, ?, ?, '…', 16)
+		FROM chat_history_fts
+		JOIN chat_history h ON h.id = chat_history_fts.rowid
+		WHERE chat_history_fts MATCH ? AND h.chat_id = ?
+		ORDER BY bm25(chat_history_fts)
+		LIMIT ?
+	`, SnippetStart, SnippetEnd, match, chatID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("помилка пошуку в історії: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.ID, &r.ConversationID, &r.Message, &r.Response, &r.CreatedAt, &r.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// GetHistoryEntry повертає запис історії користувача або nil, якщо його немає.
+func (s *Storage) GetHistoryEntry(chatID, id int64) (*HistoryEntry, error) {
+	var e HistoryEntry
+	err := s.db.QueryRow(`
+		SELECT id, conversation_id, COALESCE(message, ''), COALESCE(response, ''), created_at
+		FROM chat_history
+		WHERE id = ? AND chat_id = ?
+	`, id, chatID).Scan(&e.ID, &e.ConversationID, &e.Message, &e.Response, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання запису історії: %w", err)
+	}
+	return &e, nil
+}
+
+// ftsQuery перетворює текст користувача на запит FTS5: кожне слово береться
+// в лапки, щоб оператори і спецсимволи FTS5 не ламали запит.
+func ftsQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if word != "" {
+			terms = append(terms, `"`+word+`"*`)
+		}
+	}
+	return strings.Join(terms, " ")
+}
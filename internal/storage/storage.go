@@ -11,13 +11,15 @@ import (
 
 type Storage struct {
 	db         *sql.DB
+	keys       *KeyRing
 	modelCache map[int64]string // ✅ Додаємо кеш
 	mu         sync.RWMutex     // ✅ Додаємо м'ютекс
+	limitMu    sync.Mutex       // серіалізує перевірку і списання лімітів
 }
 
 var settingsCache = sync.Map{}
 
-func NewStorage() (*Storage, error) {
+func NewStorage(keys *KeyRing) (*Storage, error) {
 	db, err := sql.Open("sqlite", "bot.db")
 	if err != nil {
 		return nil, fmt.Errorf("❌ Помилка підключення до бази: %w", err)
@@ -31,11 +33,18 @@ func NewStorage() (*Storage, error) {
 		return nil, fmt.Errorf("❌ Помилка створення таблиць: %w", err)
 	}
 
-	return &Storage{
+	s := &Storage{
 		db:         db,
+		keys:       keys,
 		modelCache: make(map[int64]string),
 		mu:         sync.RWMutex{},
-	}, nil
+	}
+
+	if err := s.migrateAPIKeys(); err != nil {
+		return nil, fmt.Errorf("❌ Помилка шифрування API ключів: %w", err)
+	}
+
+	return s, nil
 }
 
 func createTables(db *sql.DB) error {
@@ -54,11 +63,170 @@ func createTables(db *sql.DB) error {
 		)`,
 		`CREATE TABLE IF NOT EXISTS user_settings (
 			chat_id INTEGER PRIMARY KEY,
-			model TEXT NOT NULL DEFAULT 'gpt-3.5-turbo',
+			model TEXT NOT NULL DEFAULT 'gpt-4o-mini',
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS conversations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS conversation_messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS rate_limit_tiers (
+			name TEXT PRIMARY KEY,
+			policy TEXT NOT NULL,
+			request_limit INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_limits (
+			chat_id INTEGER PRIMARY KEY,
+			tier TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS rate_limit_state (
+			chat_id INTEGER PRIMARY KEY,
+			request_count INTEGER NOT NULL DEFAULT 0,
+			tokens REAL NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS redeem_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code_hash TEXT NOT NULL UNIQUE,
+			quota INTEGER NOT NULL,
+			max_uses INTEGER NOT NULL,
+			uses INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP NOT NULL,
+			created_by INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS code_redemptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code_id INTEGER NOT NULL,
+			chat_id INTEGER NOT NULL,
+			quota INTEGER NOT NULL,
+			redeemed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS banned_users (
+			chat_id INTEGER PRIMARY KEY,
+			banned_by INTEGER NOT NULL,
+			banned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS usage_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			model TEXT NOT NULL,
+			prompt_tokens INTEGER NOT NULL,
+			completion_tokens INTEGER NOT NULL,
+			cost REAL NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS generated_images (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			prompt TEXT NOT NULL,
+			revised_prompt TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL,
+			size TEXT NOT NULL,
+			quality TEXT NOT NULL,
+			file_id TEXT NOT NULL DEFAULT '',
+			cost REAL NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			conversation_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			chunks INTEGER NOT NULL,
+			embedding_model TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS document_chunks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			document_id INTEGER NOT NULL,
+			idx INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			embedding BLOB
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_history_chat ON chat_history(chat_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversations_chat ON conversations(chat_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_generated_images_chat ON generated_images(chat_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_usage_log_chat ON usage_log(chat_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation ON conversation_messages(conversation_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_documents_conversation ON documents(conversation_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_document_chunks_document ON document_chunks(document_id, idx)`,
+	}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+	if err := migrateTables(db); err != nil {
+		return err
+	}
+	if err := createSearchIndex(db); err != nil {
+		return err
 	}
+	return seedTiers(db)
+}
 
+// migrateTables додає колонки, яких немає у базах, створених попередніми версіями
+func migrateTables(db *sql.DB) error {
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"user_settings", "system_prompt", "TEXT NOT NULL DEFAULT ''"},
+		{"user_settings", "provider", "TEXT NOT NULL DEFAULT 'openai'"},
+		{"user_settings", "base_url", "TEXT NOT NULL DEFAULT ''"},
+		{"user_settings", "spending_cap", "REAL NOT NULL DEFAULT 0"},
+		{"user_settings", "voice", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "key_version", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "key_format", "INTEGER NOT NULL DEFAULT 0"},
+		{"user_limits", "bonus_requests", "INTEGER NOT NULL DEFAULT 0"},
+		{"user_limits", "custom_limit", "INTEGER"},
+		{"user_limits", "unlimited", "INTEGER NOT NULL DEFAULT 0"},
+		{"user_settings", "active_conversation", "INTEGER NOT NULL DEFAULT 0"},
+		{"conversations", "title", "TEXT NOT NULL DEFAULT ''"},
+		{"conversations", "archived", "INTEGER NOT NULL DEFAULT 0"},
+		{"chat_history", "conversation_id", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	// До появи колонки unlimited персональний ліміт 0 означав "без обмежень"
+	hasUnlimited, err := columnExists(db, "user_limits", "unlimited")
+	if err != nil {
+		return err
+	}
+
+	for _, c := range columns {
+		if err := addColumnIfMissing(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	if !hasUnlimited {
+		if _, err := db.Exec(`
+			UPDATE user_limits SET unlimited = 1, custom_limit = NULL WHERE custom_limit = 0
+		`); err != nil {
+			return err
+		}
+	}
+
+	// Індекси і дані нових колонок заповнюються лише після міграції
+	queries := []string{
+		`CREATE INDEX IF NOT EXISTS idx_chat_history_conversation ON chat_history(conversation_id)`,
+		// До появи іменованих розмов історія належала останній розмові користувача
+		`UPDATE chat_history SET conversation_id = (
+			SELECT MAX(c.id) FROM conversations c WHERE c.chat_id = chat_history.chat_id
+		) WHERE conversation_id = 0
+			AND EXISTS (SELECT 1 FROM conversations c WHERE c.chat_id = chat_history.chat_id)`,
+	}
 	for _, query := range queries {
 		if _, err := db.Exec(query); err != nil {
 			return err
@@ -67,18 +235,62 @@ func createTables(db *sql.DB) error {
 	return nil
 }
 
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	exists, err := columnExists(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name, kind string
+			notNull    int
+			dflt       sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 func (s *Storage) SaveAPIKey(chatID int64, apiKey string) error {
+	encrypted, version, err := s.keys.encrypt(chatID, apiKey)
+	if err != nil {
+		log.Printf("Помилка шифрування API ключа: %v", err)
+		return err
+	}
+
 	stmt, err := s.db.Prepare(`
-		INSERT INTO users (chat_id, api_key) 
-		VALUES (?, ?) 
-		ON CONFLICT(chat_id) DO UPDATE SET api_key = excluded.api_key`)
+		INSERT INTO users (chat_id, api_key, key_version, key_format) 
+		VALUES (?, ?, ?, ?) 
+		ON CONFLICT(chat_id) DO UPDATE SET 
+			api_key = excluded.api_key,
+			key_version = excluded.key_version,
+			key_format = excluded.key_format`)
 	if err != nil {
 		log.Printf("Помилка підготовки SQL-запиту: %v", err)
 		return err
 	}
 	defer stmt.Close() // Гарантовано закриваємо запит після виконання
 
-	_, err = stmt.Exec(chatID, apiKey)
+	_, err = stmt.Exec(chatID, encrypted, version, keyFormatBound)
 	if err != nil {
 		log.Printf("Помилка виконання SQL-запиту: %v", err)
 	}
@@ -86,11 +298,25 @@ func (s *Storage) SaveAPIKey(chatID int64, apiKey string) error {
 }
 
 func (s *Storage) GetAPIKey(chatID int64) (string, error) {
-	var apiKey string
-	err := s.db.QueryRow("SELECT api_key FROM users WHERE chat_id = ?", chatID).Scan(&apiKey)
-	return apiKey, err
+	var (
+		encrypted string
+		version   int
+		format    int
+	)
+	err := s.db.QueryRow("SELECT api_key, key_version, key_format FROM users WHERE chat_id = ?", chatID).Scan(&encrypted, &version, &format)
+	if err != nil {
+		return "", err
+	}
+	return s.keys.decrypt(chatID, encrypted, version, format)
 }
 
+// DeleteAPIKey видаляє ключ користувача з бази.
+func (s *Storage) DeleteAPIKey(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM users WHERE chat_id = ?", chatID)
+	return err
+}
+
+// ClearHistory видаляє історію, розмови з їхнім контекстом і файли користувача.
 func (s *Storage) ClearHistory(chatID int64) error {
 	log.Printf("Починаємо очищення історії для користувача %d", chatID)
 
@@ -110,15 +336,37 @@ func (s *Storage) ClearHistory(chatID int64) error {
 		return fmt.Errorf("помилка отримання кількості видалених рядків: %w", err)
 	}
 
+	// Разом з історією видаляємо і збережений контекст розмов
+	if _, err := s.db.Exec(`
+		DELETE FROM conversation_messages
+		WHERE conversation_id IN (SELECT id FROM conversations WHERE chat_id = ?)
+	`, chatID); err != nil {
+		return fmt.Errorf("помилка видалення контексту: %w", err)
+	}
+	if err := s.deleteDocuments(chatID); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec("DELETE FROM conversations WHERE chat_id = ?", chatID); err != nil {
+		return fmt.Errorf("помилка видалення розмов: %w", err)
+	}
+
 	log.Printf("Видалено %d повідомлень для користувача %d з бази данних", rowsAffected, chatID)
 	return nil
 }
 
-func (s *Storage) SaveToHistory(chatID int64, message, response string) error {
+// SaveToHistory записує обмін у розмову. Розмова без назви отримує назву
+// з першого запиту.
+func (s *Storage) SaveToHistory(chatID, conversationID int64, message, response string) error {
 	_, err := s.db.Exec(`
-		INSERT INTO chat_history (chat_id, message, response) 
-		VALUES (?, ?, ?)
-	`, chatID, message, response)
+		INSERT INTO chat_history (chat_id, conversation_id, message, response) 
+		VALUES (?, ?, ?, ?)
+	`, chatID, conversationID, message, response)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE conversations SET title = ? WHERE id = ? AND title = ''",
+		conversationTitle(message), conversationID)
 	return err
 }
 
@@ -205,6 +453,106 @@ func (s *Storage) GetUserSettings(chatID int64) (string, error) {
 	return model, nil
 }
 
+// SaveSystemPrompt зберігає системний промпт користувача; порожній рядок вимикає його.
+func (s *Storage) SaveSystemPrompt(chatID int64, prompt string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_settings (chat_id, system_prompt) 
+		VALUES (?, ?) 
+		ON CONFLICT(chat_id) DO UPDATE SET 
+			system_prompt = excluded.system_prompt,
+			updated_at = CURRENT_TIMESTAMP
+	`, chatID, prompt)
+
+	if err != nil {
+		log.Printf("❌ Помилка збереження системного промпту: %v", err)
+	}
+	return err
+}
+
+func (s *Storage) GetSystemPrompt(chatID int64) (string, error) {
+	var prompt string
+	err := s.db.QueryRow("SELECT system_prompt FROM user_settings WHERE chat_id = ?", chatID).Scan(&prompt)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return prompt, err
+}
+
+// SaveProvider зберігає провайдера LLM і адресу сервера для OpenAI-сумісних бекендів.
+func (s *Storage) SaveProvider(chatID int64, provider, baseURL string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_settings (chat_id, provider, base_url) 
+		VALUES (?, ?, ?) 
+		ON CONFLICT(chat_id) DO UPDATE SET 
+			provider = excluded.provider,
+			base_url = excluded.base_url,
+			updated_at = CURRENT_TIMESTAMP
+	`, chatID, provider, baseURL)
+
+	if err != nil {
+		log.Printf("❌ Помилка збереження провайдера: %v", err)
+	}
+	return err
+}
+
+func (s *Storage) GetProvider(chatID int64) (provider, baseURL string, err error) {
+	err = s.db.QueryRow("SELECT provider, base_url FROM user_settings WHERE chat_id = ?", chatID).Scan(&provider, &baseURL)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	return provider, baseURL, err
+}
+
+// SaveSpendingCap зберігає місячний ліміт витрат у доларах США; 0 вимикає ліміт.
+func (s *Storage) SaveSpendingCap(chatID int64, limit float64) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_settings (chat_id, spending_cap) 
+		VALUES (?, ?) 
+		ON CONFLICT(chat_id) DO UPDATE SET 
+			spending_cap = excluded.spending_cap,
+			updated_at = CURRENT_TIMESTAMP
+	`, chatID, limit)
+
+	if err != nil {
+		log.Printf("❌ Помилка збереження ліміту витрат: %v", err)
+	}
+	return err
+}
+
+func (s *Storage) GetSpendingCap(chatID int64) (float64, error) {
+	var limit float64
+	err := s.db.QueryRow("SELECT spending_cap FROM user_settings WHERE chat_id = ?", chatID).Scan(&limit)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return limit, err
+}
+
+// SaveVoice зберігає голос для озвучення відповідей; порожній рядок вимикає озвучення.
+func (s *Storage) SaveVoice(chatID int64, voice string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_settings (chat_id, voice) 
+		VALUES (?, ?) 
+		ON CONFLICT(chat_id) DO UPDATE SET 
+			voice = excluded.voice,
+			updated_at = CURRENT_TIMESTAMP
+	`, chatID, voice)
+
+	if err != nil {
+		log.Printf("❌ Помилка збереження голосу: %v", err)
+	}
+	return err
+}
+
+func (s *Storage) GetVoice(chatID int64) (string, error) {
+	var voice string
+	err := s.db.QueryRow("SELECT voice FROM user_settings WHERE chat_id = ?", chatID).Scan(&voice)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return voice, err
+}
+
 func (s *Storage) HasHistory(chatID int64) (bool, error) {
 	var exists bool
 	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM chat_history WHERE chat_id = ?)", chatID).Scan(&exists)
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// UsageSummary - сумарне використання токенів за період
+type UsageSummary struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // оціночна вартість у доларах США
+}
+
+func (u UsageSummary) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// RecordUsage записує токени одного запиту. Вартість зберігається на момент
+// запиту, щоб зміна таблиці цін не переписувала минулі витрати.
+func (s *Storage) RecordUsage(chatID int64, model string, promptTokens, completionTokens int, cost float64) error {
+	_, err := s.db.Exec(`
+		INSERT INTO usage_log (chat_id, model, prompt_tokens, completion_tokens, cost)
+		VALUES (?, ?, ?, ?, ?)
+	`, chatID, model, promptTokens, completionTokens, cost)
+	if err != nil {
+		return fmt.Errorf("помилка збереження використання токенів: %w", err)
+	}
+	return nil
+}
+
+// GetUsageSummary повертає використання токенів користувачем починаючи з since.
+func (s *Storage) GetUsageSummary(chatID int64, since time.Time) (UsageSummary, error) {
+	var summary UsageSummary
+	// created_at заповнює SQLite (CURRENT_TIMESTAMP) у UTC
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost), 0)
+		FROM usage_log
+		WHERE chat_id = ? AND created_at >= ?
+	`, chatID, since.UTC().Format(time.DateTime)).Scan(&summary.Requests, &summary.PromptTokens, &summary.CompletionTokens, &summary.Cost)
+	if err != nil {
+		return summary, fmt.Errorf("помилка отримання використання токенів: %w", err)
+	}
+	return summary, nil
+}